@@ -0,0 +1,184 @@
+// Package clock abstracts time, so code waiting on timers could be tested with a Manual clock.
+// It depends on nothing but the standard library, to be shared by packages of any level.
+package clock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the Clock counterpart of time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Real is backed by package time
+var Real Clock = realClock{}
+
+type contextKey struct{}
+
+// With returns a copy of ctx carrying the clock
+func With(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// From returns the clock carried by ctx, or Real if there is none
+func From(ctx context.Context) Clock {
+	if c, ok := ctx.Value(contextKey{}).(Clock); ok && c != nil {
+		return c
+	}
+	return Real
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// Manual is a fake clock, time moves only when Advance or Set is called
+type Manual struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	timers  []*manualTimer
+	counter int
+}
+
+// NewManual creates a Manual starting at now
+func NewManual(now time.Time) *Manual {
+	c := &Manual{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Manual) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Manual) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &manualTimer{
+		clock:    c,
+		deadline: c.now.Add(d),
+		c:        make(chan time.Time, 1),
+		seq:      c.counter,
+	}
+	c.counter++
+
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d, and fires every timer due in order
+func (c *Manual) Advance(d time.Duration) {
+	c.mu.Lock()
+	now := c.now.Add(d)
+	c.mu.Unlock()
+	c.Set(now)
+}
+
+// Set moves the clock to now, and fires every timer due in order.
+// The clock never goes backwards, an earlier time is ignored.
+func (c *Manual) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Before(c.now) {
+		return
+	}
+
+	sort.Slice(c.timers, func(i, j int) bool {
+		if c.timers[i].deadline.Equal(c.timers[j].deadline) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+
+	fired := 0
+	for _, t := range c.timers {
+		if t.deadline.After(now) {
+			break
+		}
+		c.now = t.deadline
+		t.c <- t.deadline
+		fired++
+	}
+	c.timers = c.timers[fired:]
+	c.now = now
+	c.cond.Broadcast()
+}
+
+// Waiters returns the number of timers not fired or stopped yet
+func (c *Manual) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until there are at least n pending timers,
+// so tests know the code under test has started waiting before advancing the clock
+func (c *Manual) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+type manualTimer struct {
+	clock    *Manual
+	deadline time.Time
+	c        chan time.Time
+	seq      int
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
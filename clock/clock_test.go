@@ -0,0 +1,41 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := NewManual(start)
+
+	t1 := clk.NewTimer(time.Second)
+	t2 := clk.NewTimer(2 * time.Second)
+	t3 := clk.NewTimer(3 * time.Second)
+	assert.Equal(t, 3, clk.Waiters())
+	assert.True(t, t3.Stop())
+	assert.False(t, t3.Stop())
+
+	clk.Advance(1500 * time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-t1.C())
+	assert.Equal(t, start.Add(1500*time.Millisecond), clk.Now())
+	select {
+	case <-t2.C():
+		t.Fatal("fired too early")
+	default:
+	}
+
+	clk.Advance(time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-t2.C())
+	assert.Equal(t, 0, clk.Waiters())
+}
+
+func TestFrom(t *testing.T) {
+	assert.Equal(t, Real, From(context.Background()))
+
+	m := NewManual(time.Now())
+	assert.Equal(t, m, From(With(context.Background(), m)))
+}
@@ -7,7 +7,7 @@ import (
 	"reflect"
 
 	"github.com/imdario/mergo"
-	"github.com/pkg/errors"
+	"github.com/supremind/pkg/errs"
 )
 
 var filePath string
@@ -20,10 +20,10 @@ func init() {
 func LoadConfig(v interface{}) error {
 	buf, e := ioutil.ReadFile(filePath)
 	if e != nil {
-		return errors.Wrap(e, "read config file failed, did you set the right path?")
+		return errs.Wrap(e, "read config file failed, did you set the right path?")
 	}
 	if e := json.Unmarshal(buf, v); e != nil {
-		return errors.Wrap(e, "unmarshal config file failed, please check the file path and content")
+		return errs.Wrap(e, "unmarshal config file failed, please check the file path and content")
 	}
 	return nil
 }
@@ -35,7 +35,7 @@ func LoadWithDefault(v interface{}, cfgDefault []byte) error {
 	}
 
 	if e := json.Unmarshal(cfgDefault, v); e != nil {
-		return errors.Wrap(e, "unmarshal default config failed")
+		return errs.Wrap(e, "unmarshal default config failed")
 	}
 
 	return mergo.MergeWithOverwrite(v, vFile)
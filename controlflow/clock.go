@@ -0,0 +1,38 @@
+package controlflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/supremind/pkg/clock"
+)
+
+// Clock tells the time and creates timers, see package clock.
+// Functions in this package which wait, like Retry and Hedge, use the clock carried by their context,
+// so tests could replace the real clock with a ManualClock and advance time deterministically.
+// Backoff policies only compute durations, they do not need a clock themselves.
+type Clock = clock.Clock
+
+// Timer is the Clock counterpart of time.Timer
+type Timer = clock.Timer
+
+// ManualClock is a fake clock, time moves only when Advance or Set is called
+type ManualClock = clock.Manual
+
+// RealClock is backed by package time
+var RealClock = clock.Real
+
+// WithClock returns a copy of ctx carrying the clock
+func WithClock(ctx context.Context, c Clock) context.Context {
+	return clock.With(ctx, c)
+}
+
+// ClockFrom returns the clock carried by ctx, or RealClock if there is none
+func ClockFrom(ctx context.Context) Clock {
+	return clock.From(ctx)
+}
+
+// NewManualClock creates a ManualClock starting at now
+func NewManualClock(now time.Time) *ManualClock {
+	return clock.NewManual(now)
+}
@@ -0,0 +1,206 @@
+package controlflow
+
+import (
+	"context"
+	"sync"
+
+	"github.com/supremind/pkg/errs"
+	"golang.org/x/sync/errgroup"
+)
+
+// RetryValue works like Retry, and returns the value of the successful call
+func RetryValue[T any](ctx context.Context, attempts int, policy BackoffPolicy, f func() (T, error), opts ...RetryOption) (T, error) {
+	var v T
+	e := Retry(ctx, attempts, policy, func() (err error) {
+		v, err = f()
+		return
+	}, opts...)
+	if e != nil {
+		var zero T
+		return zero, e
+	}
+	return v, nil
+}
+
+type fanOut struct {
+	limit   int
+	collect bool
+}
+
+// FanOutOption configures ParallelMap, FirstSuccess and AllSettled
+type FanOutOption func(*fanOut)
+
+// Limit runs at most n functions at the same time, n <= 0 means no limit
+func Limit(n int) FanOutOption {
+	return func(f *fanOut) {
+		f.limit = n
+	}
+}
+
+// CollectErrors keeps going after failures, and returns every error in an errs.Errors
+func CollectErrors() FanOutOption {
+	return func(f *fanOut) {
+		f.collect = true
+	}
+}
+
+func newFanOut(opts []FanOutOption) *fanOut {
+	f := &fanOut{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+func (f *fanOut) group(ctx context.Context) (*errgroup.Group, context.Context) {
+	eg, egCtx := errgroup.WithContext(ctx)
+	if f.limit > 0 {
+		eg.SetLimit(f.limit)
+	}
+	if f.collect {
+		// a failed call should not cancel the others
+		egCtx = ctx
+	}
+	return eg, egCtx
+}
+
+// ParallelMap calls f on every element of in concurrently, and returns the results in the same order.
+// By default the first error cancels the context of other calls and is returned,
+// with CollectErrors every call runs to the end, and all errors are returned in an errs.Errors.
+func ParallelMap[T, R any](ctx context.Context, in []T, f func(ctx context.Context, v T) (R, error), opts ...FanOutOption) ([]R, error) {
+	fo := newFanOut(opts)
+	eg, ctx := fo.group(ctx)
+
+	out := make([]R, len(in))
+	failures := make([]error, len(in))
+
+	for i := range in {
+		i := i
+		eg.Go(func() error {
+			if e := ctx.Err(); e != nil {
+				failures[i] = e
+				if fo.collect {
+					return nil
+				}
+				return e
+			}
+
+			r, e := f(ctx, in[i])
+			if e != nil {
+				failures[i] = e
+				if fo.collect {
+					return nil
+				}
+				return e
+			}
+			out[i] = r
+			return nil
+		})
+	}
+
+	e := eg.Wait()
+	if fo.collect {
+		return out, collectErrors(append(failures, e))
+	}
+	if e != nil {
+		return nil, e
+	}
+	return out, nil
+}
+
+// FirstSuccess calls every function concurrently, returns the first successful result,
+// and cancels the context of the other calls.
+// If all of them fail, the first error is returned, or all errors in an errs.Errors with CollectErrors.
+func FirstSuccess[T any](ctx context.Context, fs []func(ctx context.Context) (T, error), opts ...FanOutOption) (T, error) {
+	fo := newFanOut(opts)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	eg := &errgroup.Group{}
+	if fo.limit > 0 {
+		eg.SetLimit(fo.limit)
+	}
+
+	var (
+		once     sync.Once
+		value    T
+		won      bool
+		failures = make([]error, len(fs))
+	)
+
+	for i := range fs {
+		i := i
+		eg.Go(func() error {
+			if e := ctx.Err(); e != nil {
+				failures[i] = e
+				return nil
+			}
+
+			v, e := fs[i](ctx)
+			if e != nil {
+				failures[i] = e
+				return nil
+			}
+
+			once.Do(func() {
+				value, won = v, true
+				cancel()
+			})
+			return nil
+		})
+	}
+	eg.Wait()
+
+	if won {
+		return value, nil
+	}
+
+	var zero T
+	if fo.collect {
+		return zero, collectErrors(failures)
+	}
+	for _, e := range failures {
+		if e != nil {
+			return zero, e
+		}
+	}
+	return zero, ctx.Err()
+}
+
+// Settled is the outcome of a single call in AllSettled
+type Settled[T any] struct {
+	Value T
+	Err   error
+}
+
+// AllSettled calls every function concurrently, waits for all of them, and returns their outcomes in order.
+// A failed call never cancels the others, so CollectErrors makes no difference here.
+func AllSettled[T any](ctx context.Context, fs []func(ctx context.Context) (T, error), opts ...FanOutOption) []Settled[T] {
+	fo := newFanOut(opts)
+
+	eg := &errgroup.Group{}
+	if fo.limit > 0 {
+		eg.SetLimit(fo.limit)
+	}
+
+	out := make([]Settled[T], len(fs))
+	for i := range fs {
+		i := i
+		eg.Go(func() error {
+			if e := ctx.Err(); e != nil {
+				out[i].Err = e
+				return nil
+			}
+			out[i].Value, out[i].Err = fs[i](ctx)
+			return nil
+		})
+	}
+	eg.Wait()
+
+	return out
+}
+
+func collectErrors(failures []error) error {
+	return errs.Append(nil, failures...).ErrorOrNil()
+}
@@ -0,0 +1,122 @@
+package controlflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/supremind/pkg/errs"
+)
+
+func TestRetryValue(t *testing.T) {
+	run := 0
+	v, e := RetryValue(context.Background(), 3, NoWait(), func() (string, error) {
+		run++
+		if run < 3 {
+			return "", fmt.Errorf("run %d", run)
+		}
+		return "done", nil
+	})
+	assert.NoError(t, e)
+	assert.Equal(t, "done", v)
+
+	v, e = RetryValue(context.Background(), 2, NoWait(), func() (string, error) {
+		return "partial", errors.New("failure")
+	})
+	assert.EqualError(t, e, "failure")
+	assert.Empty(t, v)
+}
+
+func TestParallelMap(t *testing.T) {
+	in := []int{1, 2, 3, 4, 5, 6}
+	square := func(_ context.Context, i int) (int, error) { return i * i, nil }
+
+	t.Run("limited", func(t *testing.T) {
+		var running, peak int32
+		out, e := ParallelMap(context.Background(), in, func(ctx context.Context, i int) (int, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return square(ctx, i)
+		}, Limit(2))
+		assert.NoError(t, e)
+		assert.Equal(t, []int{1, 4, 9, 16, 25, 36}, out)
+		assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(2))
+	})
+
+	odd := func(_ context.Context, i int) (int, error) {
+		if i%2 == 1 {
+			return 0, fmt.Errorf("odd: %d", i)
+		}
+		return i, nil
+	}
+
+	t.Run("first error", func(t *testing.T) {
+		_, e := ParallelMap(context.Background(), in, odd, Limit(1))
+		assert.EqualError(t, e, "odd: 1")
+	})
+
+	t.Run("collect errors", func(t *testing.T) {
+		out, e := ParallelMap(context.Background(), in, odd, CollectErrors())
+		var es errs.Errors
+		assert.True(t, errors.As(e, &es))
+		assert.Len(t, es, 3)
+		assert.Equal(t, []int{0, 2, 0, 4, 0, 6}, out)
+	})
+
+	t.Run("collect errors of cancelled calls", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		out, e := ParallelMap(ctx, in, odd, CollectErrors())
+		var es errs.Errors
+		assert.True(t, errors.As(e, &es))
+		assert.Len(t, es, len(in))
+		assert.True(t, errors.Is(e, context.Canceled))
+		assert.Len(t, out, len(in))
+	})
+}
+
+func TestFirstSuccess(t *testing.T) {
+	slow := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	fast := func(context.Context) (string, error) { return "fast", nil }
+	fail := func(context.Context) (string, error) { return "", errors.New("failure") }
+
+	v, e := FirstSuccess(context.Background(), []func(context.Context) (string, error){slow, fail, fast})
+	assert.NoError(t, e)
+	assert.Equal(t, "fast", v)
+
+	_, e = FirstSuccess(context.Background(), []func(context.Context) (string, error){fail, fail})
+	assert.EqualError(t, e, "failure")
+
+	_, e = FirstSuccess(context.Background(), []func(context.Context) (string, error){fail, fail}, CollectErrors())
+	var es errs.Errors
+	assert.True(t, errors.As(e, &es))
+	assert.Len(t, es, 2)
+}
+
+func TestAllSettled(t *testing.T) {
+	out := AllSettled(context.Background(), []func(context.Context) (int, error){
+		func(context.Context) (int, error) { return 1, nil },
+		func(context.Context) (int, error) { return 0, errors.New("failure") },
+		func(context.Context) (int, error) { return 3, nil },
+	}, Limit(1))
+
+	assert.Equal(t, []Settled[int]{
+		{Value: 1},
+		{Err: errors.New("failure")},
+		{Value: 3},
+	}, out)
+}
@@ -0,0 +1,79 @@
+package controlflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/supremind/pkg/errs"
+)
+
+// Hedge calls f, and sends another call every delay while none of the calls
+// has succeeded yet, up to maxHedges extra calls.
+// It returns the index of the winning attempt, 0 for the first one, together with its error.
+// Contexts passed to the losing attempts are cancelled as soon as a winner is found.
+// If every attempt fails, the error of the last finished attempt is returned, with winner -1.
+// A panicking attempt fails with an errs.PanicError.
+// Like Retry, it waits on the clock carried by ctx.
+func Hedge(ctx context.Context, delay time.Duration, maxHedges int, f func(ctx context.Context) error) (winner int, err error) {
+	return HedgeWithBackoff(ctx, StaticBackoff(delay), maxHedges, f)
+}
+
+// HedgeWithBackoff works like Hedge, but waits between attempts as the backoff policy suggests.
+func HedgeWithBackoff(ctx context.Context, policy BackoffPolicy, maxHedges int, f func(ctx context.Context) error) (winner int, err error) {
+	if maxHedges < 0 {
+		maxHedges = 0
+	}
+
+	// cancels losing attempts and the waiting goroutine once we return
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		attempt int
+		err     error
+	}
+
+	// buffered, so losing attempts never block after we return
+	results := make(chan result, maxHedges+1)
+	w := wait(ctx, ClockFrom(ctx), policy, maxHedges+1)
+
+	launched, finished := 0, 0
+	for {
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+
+		case _, ok := <-w:
+			if !ok {
+				// no more hedges to send, wait for the running ones
+				w = nil
+				if finished == launched {
+					return -1, err
+				}
+				continue
+			}
+
+			go func(attempt int) {
+				var err error
+				defer func() {
+					results <- result{attempt: attempt, err: err}
+				}()
+				defer errs.Recover(&err)
+
+				err = f(ctx)
+			}(launched)
+			launched++
+
+		case r := <-results:
+			finished++
+			if r.err == nil {
+				return r.attempt, nil
+			}
+			err = r.err
+
+			if w == nil && finished == launched {
+				return -1, err
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package controlflow
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/supremind/pkg/errs"
+)
+
+func TestHedge(t *testing.T) {
+	t.Run("first wins", func(t *testing.T) {
+		winner, e := Hedge(context.Background(), 50*time.Millisecond, 2, func(context.Context) error {
+			return nil
+		})
+		assert.NoError(t, e)
+		assert.Equal(t, 0, winner)
+	})
+
+	t.Run("hedge wins", func(t *testing.T) {
+		var calls int32
+		var cancelled int32
+		winner, e := Hedge(context.Background(), 5*time.Millisecond, 2, func(ctx context.Context) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				// the slow one
+				<-ctx.Done()
+				atomic.AddInt32(&cancelled, 1)
+				return ctx.Err()
+			}
+			return nil
+		})
+		assert.NoError(t, e)
+		assert.Equal(t, 1, winner)
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&cancelled) == 1 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("all fail", func(t *testing.T) {
+		var calls int32
+		failure := errors.New("failure")
+		winner, e := Hedge(context.Background(), time.Millisecond, 3, func(context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return failure
+		})
+		assert.Equal(t, failure, e)
+		assert.Equal(t, -1, winner)
+		assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		winner, e := Hedge(ctx, time.Millisecond, 3, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		assert.Equal(t, context.DeadlineExceeded, e)
+		assert.Equal(t, -1, winner)
+	})
+}
+
+func TestHedgePanic(t *testing.T) {
+	winner, e := Hedge(context.Background(), time.Millisecond, 1, func(context.Context) error {
+		panic("boom")
+	})
+	assert.Equal(t, -1, winner)
+	var p *errs.PanicError
+	if assert.True(t, errors.As(e, &p), "%v", e) {
+		assert.Equal(t, "boom", p.Value)
+	}
+
+	var calls int32
+	winner, e = Hedge(context.Background(), time.Millisecond, 1, func(context.Context) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			panic("boom")
+		}
+		return nil
+	})
+	assert.NoError(t, e)
+	assert.Equal(t, 1, winner)
+}
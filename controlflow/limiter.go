@@ -0,0 +1,329 @@
+package controlflow
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrLimitExceeded is returned by TryAcquire when the limiter is full
+var ErrLimitExceeded = errors.New("concurrency limit exceeded")
+
+// Outcome tells a Limiter how a guarded call went
+type Outcome int
+
+const (
+	// Success means the call finished, and its round trip time is a valid sample
+	Success Outcome = iota
+	// Dropped means the call was rejected or timed out by the dependency, which is a sign of overload
+	Dropped
+	// Ignored means the call failed for other reasons, its round trip time tells nothing about the dependency
+	Ignored
+)
+
+// Sample is a measurement of a finished call
+type Sample struct {
+	RTT      time.Duration
+	InFlight int
+	Dropped  bool
+}
+
+// LimitAlgorithm computes the next concurrency limit from the current one and a new sample.
+// Limiter serializes calls to Update, so implementations could keep state without locking.
+type LimitAlgorithm interface {
+	Update(limit int, s Sample) int
+}
+
+// Limiter caps the number of in-flight calls to a dependency.
+// The cap is tuned by a LimitAlgorithm from round trip times of finished calls,
+// or stays fixed for a bulkhead.
+type Limiter struct {
+	mu       sync.Mutex
+	algo     LimitAlgorithm
+	limit    int
+	inFlight int
+	waiters  []chan struct{}
+}
+
+// NewLimiter creates a Limiter starting at the initial limit, tuned by algo
+func NewLimiter(initial int, algo LimitAlgorithm) *Limiter {
+	if initial < 1 {
+		initial = 1
+	}
+	return &Limiter{
+		algo:  algo,
+		limit: initial,
+	}
+}
+
+// NewBulkhead creates a Limiter allowing at most size in-flight calls, the limit never changes
+func NewBulkhead(size int) *Limiter {
+	return NewLimiter(size, nil)
+}
+
+// Permit is granted by a Limiter, and must be released exactly once
+type Permit struct {
+	l     *Limiter
+	clock Clock
+	start time.Time
+	once  sync.Once
+}
+
+// Acquire blocks until a permit is available or ctx is done.
+// The round trip time is measured with the clock carried by ctx.
+func (l *Limiter) Acquire(ctx context.Context) (*Permit, error) {
+	l.mu.Lock()
+	if len(l.waiters) == 0 && l.inFlight < l.limit {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.newPermit(ctx), nil
+	}
+
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return l.newPermit(ctx), nil
+
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		for i, w := range l.waiters {
+			if w == ready {
+				l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+				return nil, ctx.Err()
+			}
+		}
+
+		// granted while giving up, pass it on
+		l.inFlight--
+		l.wake()
+		return nil, ctx.Err()
+	}
+}
+
+// TryAcquire returns a permit if one is available right now, or ErrLimitExceeded
+func (l *Limiter) TryAcquire(ctx context.Context) (*Permit, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.waiters) > 0 || l.inFlight >= l.limit {
+		return nil, ErrLimitExceeded
+	}
+	l.inFlight++
+	return l.newPermit(ctx), nil
+}
+
+// Do calls f with a permit, and releases it with an outcome judged from the error:
+// nil is a Success, context.DeadlineExceeded is Dropped, any other error is Ignored.
+// A panicking f releases the permit as Ignored.
+// It fits in the function given to Retry.
+func (l *Limiter) Do(ctx context.Context, f func() error) (err error) {
+	p, e := l.Acquire(ctx)
+	if e != nil {
+		return e
+	}
+
+	o := Ignored
+	defer func() {
+		p.Release(o)
+	}()
+
+	err = f()
+	switch {
+	case err == nil:
+		o = Success
+	case errors.Is(err, context.DeadlineExceeded):
+		o = Dropped
+	}
+	return err
+}
+
+// Limit returns the current concurrency limit
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InFlight returns the number of permits not released yet
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+func (l *Limiter) newPermit(ctx context.Context) *Permit {
+	clock := ClockFrom(ctx)
+	return &Permit{l: l, clock: clock, start: clock.Now()}
+}
+
+// Release gives the permit back, further calls are no-ops
+func (p *Permit) Release(o Outcome) {
+	p.once.Do(func() {
+		rtt := p.clock.Now().Sub(p.start)
+		p.l.release(o, rtt)
+	})
+}
+
+func (l *Limiter) release(o Outcome, rtt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.algo != nil && o != Ignored {
+		limit := l.algo.Update(l.limit, Sample{RTT: rtt, InFlight: l.inFlight, Dropped: o == Dropped})
+		if limit < 1 {
+			limit = 1
+		}
+		l.limit = limit
+	}
+
+	l.inFlight--
+	l.wake()
+}
+
+// wake grants permits to waiters in order while there is room, l.mu must be held
+func (l *Limiter) wake() {
+	for len(l.waiters) > 0 && l.inFlight < l.limit {
+		w := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		l.inFlight++
+		close(w)
+	}
+}
+
+// AIMD increases the limit by one after a successful call made while the limiter was busy,
+// and multiplies it by backoff (in (0, 1)) after a drop, keeping it within [min, max].
+func AIMD(min, max int, backoff float64) LimitAlgorithm {
+	if backoff <= 0 || backoff >= 1 {
+		backoff = 0.9
+	}
+	return &aimd{min: min, max: max, backoff: backoff}
+}
+
+type aimd struct {
+	min, max int
+	backoff  float64
+}
+
+func (a *aimd) Update(limit int, s Sample) int {
+	switch {
+	case s.Dropped:
+		limit = int(float64(limit) * a.backoff)
+	case s.InFlight*2 >= limit:
+		// only grow when the limit is actually used
+		limit++
+	}
+	return clampLimit(limit, a.min, a.max)
+}
+
+// Vegas estimates the queue at the dependency from the ratio of the lowest seen RTT to the current one,
+// grows the limit while the queue is short and shrinks it when the queue builds up or a call is dropped,
+// like the TCP Vegas congestion control.
+func Vegas(min, max int) LimitAlgorithm {
+	return &vegas{min: min, max: max}
+}
+
+type vegas struct {
+	min, max int
+	noLoad   time.Duration
+}
+
+func (v *vegas) Update(limit int, s Sample) int {
+	if s.RTT <= 0 {
+		return limit
+	}
+	if v.noLoad <= 0 || s.RTT < v.noLoad {
+		v.noLoad = s.RTT
+	}
+
+	l := float64(limit)
+	step := math.Max(1, math.Log10(l))
+
+	switch {
+	case s.Dropped:
+		l -= step
+	case s.InFlight*2 < limit:
+		// not busy enough to tell
+	default:
+		queue := math.Ceil(l * (1 - float64(v.noLoad)/float64(s.RTT)))
+		alpha, beta := 3*step, 6*step
+		switch {
+		case queue <= step:
+			l += beta
+		case queue < alpha:
+			l += step
+		case queue > beta:
+			l -= step
+		}
+	}
+
+	return clampLimit(int(l), v.min, v.max)
+}
+
+// Gradient compares the current RTT with a long term average of RTTs,
+// and scales the limit by their ratio, with some headroom for queueing. A drop halves the limit.
+func Gradient(min, max int) LimitAlgorithm {
+	return &gradient{min: min, max: max}
+}
+
+const (
+	gradientWindow    = 600
+	gradientTolerance = 1.5
+	gradientSmoothing = 0.2
+)
+
+type gradient struct {
+	min, max int
+	longRTT  float64
+	samples  int
+	estimate float64
+}
+
+func (g *gradient) Update(limit int, s Sample) int {
+	if s.RTT <= 0 {
+		return limit
+	}
+
+	rtt := float64(s.RTT)
+	if g.samples < gradientWindow {
+		g.samples++
+	}
+	g.longRTT += (rtt - g.longRTT) / float64(g.samples)
+
+	if g.estimate <= 0 {
+		g.estimate = float64(limit)
+	}
+
+	if s.Dropped {
+		g.estimate = g.estimate / 2
+	} else if s.InFlight*2 >= limit {
+		grad := math.Max(0.5, math.Min(1, gradientTolerance*g.longRTT/rtt))
+		next := g.estimate*grad + math.Sqrt(g.estimate)
+		g.estimate = g.estimate*(1-gradientSmoothing) + next*gradientSmoothing
+	}
+
+	g.estimate = math.Max(float64(g.min), g.estimate)
+	if g.max > 0 {
+		g.estimate = math.Min(float64(g.max), g.estimate)
+	}
+	return clampLimit(int(g.estimate), g.min, g.max)
+}
+
+func clampLimit(limit, min, max int) int {
+	if min < 1 {
+		min = 1
+	}
+	if limit < min {
+		limit = min
+	}
+	if max > 0 && limit > max {
+		limit = max
+	}
+	return limit
+}
@@ -0,0 +1,127 @@
+package controlflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkhead(t *testing.T) {
+	l := NewBulkhead(2)
+	ctx := context.Background()
+
+	p1, e := l.Acquire(ctx)
+	assert.NoError(t, e)
+	p2, e := l.Acquire(ctx)
+	assert.NoError(t, e)
+
+	_, e = l.TryAcquire(ctx)
+	assert.Equal(t, ErrLimitExceeded, e)
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, e = l.Acquire(timeout)
+	assert.Equal(t, context.DeadlineExceeded, e)
+
+	got := make(chan *Permit)
+	go func() {
+		p, _ := l.Acquire(ctx)
+		got <- p
+	}()
+
+	p1.Release(Success)
+	p1.Release(Success) // no-op
+	p3 := <-got
+	assert.Equal(t, 2, l.InFlight())
+	assert.Equal(t, 2, l.Limit())
+
+	p2.Release(Dropped)
+	p3.Release(Ignored)
+	assert.Equal(t, 0, l.InFlight())
+	assert.Equal(t, 2, l.Limit())
+}
+
+func TestAIMD(t *testing.T) {
+	a := AIMD(2, 10, 0.5)
+	assert.Equal(t, 5, a.Update(4, Sample{RTT: time.Millisecond, InFlight: 4}))
+	assert.Equal(t, 4, a.Update(4, Sample{RTT: time.Millisecond, InFlight: 1}))
+	assert.Equal(t, 2, a.Update(4, Sample{RTT: time.Millisecond, InFlight: 4, Dropped: true}))
+	assert.Equal(t, 2, a.Update(2, Sample{RTT: time.Millisecond, InFlight: 2, Dropped: true}))
+	assert.Equal(t, 10, a.Update(10, Sample{RTT: time.Millisecond, InFlight: 10}))
+}
+
+func TestVegas(t *testing.T) {
+	v := Vegas(1, 100)
+	limit := 10
+	for i := 0; i < 5; i++ {
+		limit = v.Update(limit, Sample{RTT: 10 * time.Millisecond, InFlight: limit})
+	}
+	assert.Greater(t, limit, 10)
+
+	grown := limit
+	for i := 0; i < 5; i++ {
+		limit = v.Update(limit, Sample{RTT: 100 * time.Millisecond, InFlight: limit})
+	}
+	assert.Less(t, limit, grown)
+}
+
+func TestGradient(t *testing.T) {
+	g := Gradient(1, 100)
+	limit := 10
+	for i := 0; i < 20; i++ {
+		limit = g.Update(limit, Sample{RTT: 10 * time.Millisecond, InFlight: limit})
+	}
+	assert.Greater(t, limit, 10)
+
+	grown := limit
+	for i := 0; i < 20; i++ {
+		limit = g.Update(limit, Sample{RTT: 100 * time.Millisecond, InFlight: limit})
+	}
+	assert.Less(t, limit, grown)
+}
+
+func TestLimiterWithRetry(t *testing.T) {
+	clk := NewManualClock(time.Now())
+	ctx := WithClock(context.Background(), clk)
+	l := NewLimiter(4, AIMD(1, 8, 0.5))
+
+	run := 0
+	e := Retry(ctx, 3, NoWait(), func() error {
+		return l.Do(ctx, func() error {
+			run++
+			if run < 3 {
+				return context.DeadlineExceeded
+			}
+			return nil
+		})
+	})
+	assert.NoError(t, e)
+	// halved twice, then grown by one
+	assert.Equal(t, 2, l.Limit())
+
+	e = l.Do(ctx, func() error { return errors.New("not the dependency's fault") })
+	assert.Error(t, e)
+	assert.Equal(t, 2, l.Limit())
+	assert.Equal(t, 0, l.InFlight())
+}
+
+func TestLimiterPanic(t *testing.T) {
+	l := NewBulkhead(1)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		e := Retry(ctx, 1, NoWait(), func() error {
+			return l.Do(ctx, func() error {
+				panic("boom")
+			})
+		})
+		assert.Error(t, e)
+		assert.Equal(t, 0, l.InFlight())
+	}
+
+	_, e := l.TryAcquire(ctx)
+	assert.NoError(t, e)
+}
@@ -0,0 +1,368 @@
+package controlflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/supremind/pkg/errs"
+)
+
+var (
+	// ErrJobNotFound is returned by a JobStore for unknown job IDs
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobExists is returned by Enqueue for an ID already in the queue
+	ErrJobExists = errors.New("job already exists")
+	// ErrQueueRunning is returned by Run if the queue is being run already
+	ErrQueueRunning = errors.New("queue is running already")
+)
+
+// JobState is the state of a Job in a Queue
+type JobState string
+
+const (
+	// JobPending jobs wait for their next attempt
+	JobPending JobState = "pending"
+	// JobRunning jobs are being handled, they are found in this state after a crash
+	JobRunning JobState = "running"
+	// JobDead jobs have used up all attempts, they stay in the dead-letter list until requeued or deleted
+	JobDead JobState = "dead"
+)
+
+// Attempt records a single try of a Job
+type Attempt struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Job is a unit of work in a Queue
+type Job struct {
+	ID       string    `json:"id"`
+	Payload  []byte    `json:"payload"`
+	State    JobState  `json:"state"`
+	Attempts []Attempt `json:"attempts,omitempty"`
+	// Tries counts attempts since the job was enqueued or last requeued
+	Tries     int           `json:"tries"`
+	NextAt    time.Time     `json:"next_at"`
+	Backoff   time.Duration `json:"backoff"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// JobStore persists jobs of a Queue, a job is overwritten as a whole by Put
+type JobStore interface {
+	Put(ctx context.Context, job *Job) error
+	Get(ctx context.Context, id string) (*Job, error)
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context) ([]*Job, error)
+}
+
+// Queue runs jobs with a handler, and schedules failed jobs for another attempt with a backoff policy.
+// Jobs are kept in a JobStore, so with a durable store they survive restarts.
+// Successful jobs are deleted, jobs failing all attempts are moved to the dead-letter list.
+type Queue struct {
+	store    JobStore
+	attempts int
+	policy   BackoffPolicy
+	handler  func(ctx context.Context, job *Job) error
+	wake     chan struct{}
+	// mu makes checks and writes of Enqueue and Requeue atomic, and guards running
+	mu      sync.Mutex
+	running bool
+}
+
+// NewQueue creates a Queue, attempts <= 0 means retrying forever
+func NewQueue(store JobStore, attempts int, policy BackoffPolicy, handler func(ctx context.Context, job *Job) error) *Queue {
+	return &Queue{
+		store:    store,
+		attempts: attempts,
+		policy:   policy,
+		handler:  handler,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds a job to be run as soon as possible
+func (q *Queue) Enqueue(ctx context.Context, id string, payload []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, e := q.store.Get(ctx, id); e == nil {
+		return fmt.Errorf("%w: %s", ErrJobExists, id)
+	} else if !errors.Is(e, ErrJobNotFound) {
+		return e
+	}
+
+	now := ClockFrom(ctx).Now()
+	job := &Job{
+		ID:        id,
+		Payload:   payload,
+		State:     JobPending,
+		NextAt:    now,
+		CreatedAt: now,
+	}
+	if e := q.store.Put(ctx, job); e != nil {
+		return e
+	}
+
+	q.notify()
+	return nil
+}
+
+// DeadLetters lists jobs which have used up all attempts
+func (q *Queue) DeadLetters(ctx context.Context) ([]*Job, error) {
+	jobs, e := q.store.List(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	var dead []*Job
+	for _, job := range jobs {
+		if job.State == JobDead {
+			dead = append(dead, job)
+		}
+	}
+	sortJobs(dead)
+	return dead, nil
+}
+
+// Requeue moves a dead job back to the queue with a fresh set of attempts, its history is kept
+func (q *Queue) Requeue(ctx context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, e := q.store.Get(ctx, id)
+	if e != nil {
+		return e
+	}
+	if job.State != JobDead {
+		return fmt.Errorf("requeue job %s in state %s", id, job.State)
+	}
+
+	job.State = JobPending
+	job.NextAt = ClockFrom(ctx).Now()
+	job.Backoff = 0
+	job.Tries = 0
+	if e := q.store.Put(ctx, job); e != nil {
+		return e
+	}
+
+	q.notify()
+	return nil
+}
+
+// Run handles due jobs one by one until ctx is done.
+// Jobs left running by a previous crash are recorded as an interrupted attempt and scheduled again.
+// It waits on the clock carried by ctx.
+//
+// A queue has a single consumer: Run returns ErrQueueRunning while another Run of the queue is in progress,
+// and a store must not be shared by several queues, since jobs running in one look interrupted to the others.
+func (q *Queue) Run(ctx context.Context) error {
+	q.mu.Lock()
+	if q.running {
+		q.mu.Unlock()
+		return ErrQueueRunning
+	}
+	q.running = true
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		q.running = false
+		q.mu.Unlock()
+	}()
+
+	if e := q.resume(ctx); e != nil {
+		return e
+	}
+
+	clock := ClockFrom(ctx)
+	for {
+		next, e := q.runDue(ctx)
+		if e != nil {
+			return e
+		}
+
+		var tm Timer
+		var timeout <-chan time.Time
+		if !next.IsZero() {
+			tm = clock.NewTimer(next.Sub(clock.Now()))
+			timeout = tm.C()
+		}
+
+		select {
+		case <-ctx.Done():
+			e = ctx.Err()
+		case <-q.wake:
+		case <-timeout:
+		}
+
+		if tm != nil {
+			tm.Stop()
+		}
+		if e != nil {
+			return e
+		}
+	}
+}
+
+func (q *Queue) resume(ctx context.Context) error {
+	jobs, e := q.store.List(ctx)
+	if e != nil {
+		return e
+	}
+
+	now := ClockFrom(ctx).Now()
+	for _, job := range jobs {
+		if job.State != JobRunning {
+			continue
+		}
+		if e := q.fail(ctx, job, now, errors.New("interrupted")); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// runDue runs every due job, and returns when the next pending job is due, or zero if there is none
+func (q *Queue) runDue(ctx context.Context) (next time.Time, e error) {
+	clock := ClockFrom(ctx)
+
+	for {
+		if e := ctx.Err(); e != nil {
+			return time.Time{}, e
+		}
+
+		jobs, e := q.store.List(ctx)
+		if e != nil {
+			return time.Time{}, e
+		}
+		sortJobs(jobs)
+
+		now := clock.Now()
+		var due *Job
+		next = time.Time{}
+		for _, job := range jobs {
+			if job.State != JobPending {
+				continue
+			}
+			if !job.NextAt.After(now) {
+				due = job
+				break
+			}
+			if next.IsZero() || job.NextAt.Before(next) {
+				next = job.NextAt
+			}
+		}
+
+		if due == nil {
+			return next, nil
+		}
+		if e := q.run(ctx, due); e != nil {
+			return time.Time{}, e
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, job *Job) error {
+	clock := ClockFrom(ctx)
+
+	job.State = JobRunning
+	if e := q.store.Put(ctx, job); e != nil {
+		return e
+	}
+
+	at := clock.Now()
+	if e := q.handle(ctx, job); e != nil {
+		return q.fail(ctx, job, at, e)
+	}
+	return q.store.Delete(ctx, job.ID)
+}
+
+func (q *Queue) handle(ctx context.Context, job *Job) (err error) {
+	defer errs.Recover(&err)
+	return q.handler(ctx, job)
+}
+
+func (q *Queue) fail(ctx context.Context, job *Job, at time.Time, cause error) error {
+	job.Attempts = append(job.Attempts, Attempt{At: at, Error: cause.Error()})
+	job.Tries++
+
+	if q.attempts > 0 && job.Tries >= q.attempts {
+		job.State = JobDead
+		return q.store.Put(ctx, job)
+	}
+
+	job.State = JobPending
+	job.Backoff = q.policy(job.Backoff)
+	job.NextAt = ClockFrom(ctx).Now().Add(job.Backoff)
+	return q.store.Put(ctx, job)
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func sortJobs(jobs []*Job) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].NextAt.Equal(jobs[j].NextAt) {
+			return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+		}
+		return jobs[i].NextAt.Before(jobs[j].NextAt)
+	})
+}
+
+// MemoryJobStore keeps jobs in memory, it is meant for tests
+type MemoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{jobs: make(map[string]*Job)}
+}
+
+func (s *MemoryJobStore) Put(_ context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (s *MemoryJobStore) Get(_ context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return copyJob(job), nil
+}
+
+func (s *MemoryJobStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+func (s *MemoryJobStore) List(_ context.Context) ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, copyJob(job))
+	}
+	return jobs, nil
+}
+
+func copyJob(job *Job) *Job {
+	c := *job
+	c.Payload = append([]byte(nil), job.Payload...)
+	c.Attempts = append([]Attempt(nil), job.Attempts...)
+	return &c
+}
@@ -0,0 +1,102 @@
+package controlflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/supremind/pkg/internal/atomicfile"
+)
+
+const jobFileExt = ".json"
+
+// FileJobStore keeps every job as a json file in a directory.
+// Files are replaced atomically, so a crash leaves either the old or the new version of a job.
+type FileJobStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileJobStore creates a FileJobStore in dir, creating the directory if needed
+func NewFileJobStore(dir string) (*FileJobStore, error) {
+	if e := os.MkdirAll(dir, 0700); e != nil {
+		return nil, fmt.Errorf("create job store directory: %w", e)
+	}
+	return &FileJobStore{dir: dir}, nil
+}
+
+func (s *FileJobStore) Put(_ context.Context, job *Job) error {
+	b, e := json.Marshal(job)
+	if e != nil {
+		return fmt.Errorf("encode job %s: %w", job.ID, e)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return atomicfile.Write(s.path(job.ID), b, 0600, true)
+}
+
+func (s *FileJobStore) Get(_ context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(s.path(id))
+}
+
+func (s *FileJobStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := os.Remove(s.path(id)); e != nil && !os.IsNotExist(e) {
+		return fmt.Errorf("delete job %s: %w", id, e)
+	}
+	return nil
+}
+
+func (s *FileJobStore) List(_ context.Context) ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos, e := ioutil.ReadDir(s.dir)
+	if e != nil {
+		return nil, fmt.Errorf("list jobs: %w", e)
+	}
+
+	var jobs []*Job
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), jobFileExt) {
+			continue
+		}
+		job, e := s.read(filepath.Join(s.dir, info.Name()))
+		if e != nil {
+			return nil, e
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func (s *FileJobStore) path(id string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+jobFileExt)
+}
+
+func (s *FileJobStore) read(path string) (*Job, error) {
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		if os.IsNotExist(e) {
+			return nil, fmt.Errorf("%w: %s", ErrJobNotFound, filepath.Base(path))
+		}
+		return nil, fmt.Errorf("read job: %w", e)
+	}
+
+	job := &Job{}
+	if e := json.Unmarshal(b, job); e != nil {
+		return nil, fmt.Errorf("decode job %s: %w", filepath.Base(path), e)
+	}
+	return job, nil
+}
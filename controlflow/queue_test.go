@@ -0,0 +1,132 @@
+package controlflow
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := NewManualClock(start)
+	ctx, cancel := context.WithCancel(WithClock(context.Background(), clk))
+	defer cancel()
+
+	type call struct {
+		id string
+		at time.Time
+	}
+	calls := make(chan call)
+
+	q := NewQueue(NewMemoryJobStore(), 3, ExponentialBackoff(time.Second, time.Minute), func(ctx context.Context, job *Job) error {
+		calls <- call{id: job.ID, at: clk.Now()}
+		if string(job.Payload) == "ok" && len(job.Attempts) >= 1 {
+			return nil
+		}
+		return errors.New("delivery failed")
+	})
+
+	require.NoError(t, q.Enqueue(ctx, "a", []byte("ok")))
+	assert.True(t, errors.Is(q.Enqueue(ctx, "a", nil), ErrJobExists))
+
+	done := make(chan error)
+	go func() { done <- q.Run(ctx) }()
+
+	assert.Equal(t, call{id: "a", at: start}, <-calls)
+	assert.Equal(t, ErrQueueRunning, q.Run(ctx))
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	assert.Equal(t, call{id: "a", at: start.Add(time.Second)}, <-calls)
+
+	require.NoError(t, q.Enqueue(ctx, "b", []byte("fail")))
+	assert.Equal(t, call{id: "b", at: start.Add(time.Second)}, <-calls)
+	for _, d := range []time.Duration{time.Second, 2 * time.Second} {
+		clk.BlockUntil(1)
+		clk.Advance(d)
+		assert.Equal(t, "b", (<-calls).id)
+	}
+
+	var dead []*Job
+	assert.Eventually(t, func() bool {
+		dead, _ = q.DeadLetters(ctx)
+		return len(dead) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "b", dead[0].ID)
+	assert.Len(t, dead[0].Attempts, 3)
+	assert.Equal(t, "delivery failed", dead[0].Attempts[2].Error)
+
+	require.NoError(t, q.Requeue(ctx, "b"))
+	assert.Equal(t, "b", (<-calls).id)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	// runs again once stopped
+	assert.Equal(t, context.Canceled, q.Run(ctx))
+}
+
+func TestQueueResume(t *testing.T) {
+	dir, e := ioutil.TempDir("", "queue")
+	require.NoError(t, e)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store, e := NewFileJobStore(dir)
+	require.NoError(t, e)
+	// left running by a crashed process
+	require.NoError(t, store.Put(ctx, &Job{ID: "hook/1", Payload: []byte("payload"), State: JobRunning}))
+
+	// a new process with a new store on the same directory
+	store, e = NewFileJobStore(dir)
+	require.NoError(t, e)
+
+	handled := make(chan *Job)
+	q := NewQueue(store, 0, NoWait(), func(ctx context.Context, job *Job) error {
+		handled <- job
+		return nil
+	})
+	go q.Run(ctx)
+
+	job := <-handled
+	assert.Equal(t, "hook/1", job.ID)
+	assert.Equal(t, []byte("payload"), job.Payload)
+	require.Len(t, job.Attempts, 1)
+	assert.Equal(t, "interrupted", job.Attempts[0].Error)
+
+	assert.Eventually(t, func() bool {
+		_, e := store.Get(ctx, "hook/1")
+		return errors.Is(e, ErrJobNotFound)
+	}, time.Second, time.Millisecond)
+}
+
+func TestQueueEnqueueConcurrently(t *testing.T) {
+	ctx := context.Background()
+	q := NewQueue(NewMemoryJobStore(), 0, NoWait(), func(ctx context.Context, job *Job) error {
+		return nil
+	})
+
+	const n = 20
+	results := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			results <- q.Enqueue(ctx, "job", nil)
+		}()
+	}
+
+	created := 0
+	for i := 0; i < n; i++ {
+		if e := <-results; e == nil {
+			created++
+		} else {
+			assert.True(t, errors.Is(e, ErrJobExists), "%v", e)
+		}
+	}
+	assert.Equal(t, 1, created)
+}
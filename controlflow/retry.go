@@ -2,21 +2,41 @@ package controlflow
 
 import (
 	"context"
-	"fmt"
 	"math/rand"
-	"runtime/debug"
 	"time"
+
+	"github.com/supremind/pkg/errs"
 )
 
+// RetryOption configures Retry and RetryValue
+type RetryOption func(*retry)
+
+type retry struct {
+	clock Clock
+}
+
+// RetryClock makes Retry wait on the clock, instead of the one carried by ctx
+func RetryClock(c Clock) RetryOption {
+	return func(r *retry) {
+		r.clock = c
+	}
+}
+
 // Retry calls the function with given backoff.
-func Retry(ctx context.Context, attempts int, policy BackoffPolicy, f func() error) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("panic: %v, %s", r, string(debug.Stack()))
-		}
-	}()
+// It waits on the clock given by RetryClock, or the one carried by ctx, see WithClock.
+func Retry(ctx context.Context, attempts int, policy BackoffPolicy, f func() error, opts ...RetryOption) (err error) {
+	defer errs.Recover(&err)
+
+	r := retry{clock: ClockFrom(ctx)}
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	// stops the waiting goroutine once we return
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	w := wait(ctx, policy, attempts)
+	w := wait(ctx, r.clock, policy, attempts)
 
 	for {
 		select {
@@ -39,30 +59,36 @@ func Retry(ctx context.Context, attempts int, policy BackoffPolicy, f func() err
 // BackoffPolicy returns next wait duration
 type BackoffPolicy func(last time.Duration) time.Duration
 
-func wait(ctx context.Context, next BackoffPolicy, attempts int) <-chan struct{} {
+func wait(ctx context.Context, clock Clock, next BackoffPolicy, attempts int) <-chan struct{} {
 	goon := make(chan struct{})
 
 	go func() {
 		defer close(goon)
 
 		// do not wait before first run
-		goon <- struct{}{}
+		select {
+		case <-ctx.Done():
+			return
+		case goon <- struct{}{}:
+		}
 
 		dur := time.Duration(0)
 		for run := 1; attempts <= 0 || run < attempts; run++ {
 			dur = next(dur)
-			if dur <= 0 {
-				goon <- struct{}{}
-				continue
+			if dur > 0 {
+				tm := clock.NewTimer(dur)
+				select {
+				case <-ctx.Done():
+					tm.Stop()
+					return
+				case <-tm.C():
+				}
 			}
 
-			tic := time.NewTicker(dur)
 			select {
 			case <-ctx.Done():
-				tic.Stop()
 				return
-			case <-tic.C:
-				goon <- struct{}{}
+			case goon <- struct{}{}:
 			}
 		}
 	}()
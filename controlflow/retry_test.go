@@ -2,42 +2,104 @@ package controlflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/supremind/pkg/errs"
 )
 
 func TestRetry(t *testing.T) {
-	policies := map[string]BackoffPolicy{
-		"exponential": ExponentialBackoff(time.Millisecond, 20*time.Millisecond),
-		"random":      RandomBackoff(10*time.Millisecond, 20*time.Millisecond),
-		"static":      StaticBackoff(time.Millisecond),
-		"no wait":     NoWait(),
+	// each wait is within [min, max]
+	type wait struct{ min, max time.Duration }
+	policies := map[string]struct {
+		policy BackoffPolicy
+		waits  []wait
+	}{
+		"exponential": {ExponentialBackoff(time.Second, 5*time.Second), []wait{{time.Second, time.Second}, {2 * time.Second, 2 * time.Second}, {4 * time.Second, 4 * time.Second}, {5 * time.Second, 5 * time.Second}}},
+		"random":      {RandomBackoff(time.Second, 2*time.Second), []wait{{time.Second, 2 * time.Second}, {time.Second, 2 * time.Second}, {time.Second, 2 * time.Second}, {time.Second, 2 * time.Second}}},
+		"static":      {StaticBackoff(time.Second), []wait{{time.Second, time.Second}, {time.Second, time.Second}, {time.Second, time.Second}, {time.Second, time.Second}}},
+		"no wait":     {NoWait(), []wait{{}, {}, {}, {}}},
 	}
 
-	attempts := 5
-
-	for name, policy := range policies {
+	for name, c := range policies {
 		t.Run(name, func(t *testing.T) {
-			run := 0
-			lastRun := time.Now()
-
-			e := Retry(context.Background(), attempts, policy, func() error {
-				run++
-				now := time.Now()
-				wait := now.Sub(lastRun).Milliseconds()
-				lastRun = now
-				e := fmt.Errorf("[%s][%d/%d]: run after: %d", name, run, attempts, wait)
-				fmt.Println(e.Error())
-				if run >= attempts {
-					return nil
+			start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			clk := NewManualClock(start)
+			attempts := len(c.waits) + 1
+			runs := make(chan time.Time)
+			done := make(chan error)
+
+			go func() {
+				run := 0
+				done <- Retry(context.Background(), attempts, c.policy, func() error {
+					run++
+					runs <- clk.Now()
+					if run >= attempts {
+						return nil
+					}
+					return fmt.Errorf("run %d", run)
+				}, RetryClock(clk))
+			}()
+
+			assert.Equal(t, start, <-runs)
+			for _, w := range c.waits {
+				if w.max > 0 {
+					clk.BlockUntil(1)
+					clk.Advance(w.min - time.Nanosecond)
+					assert.Equal(t, 1, clk.Waiters(), "fired before %s", w.min)
+					clk.Advance(w.max - w.min + time.Nanosecond)
 				}
-				return e
-			})
+				assert.Equal(t, clk.Now(), <-runs)
+			}
+
+			assert.NoError(t, <-done)
+			assert.Equal(t, 0, clk.Waiters())
+		})
+	}
+}
 
-			assert.NoError(t, e)
+func TestRetryPanic(t *testing.T) {
+	e := Retry(context.Background(), 3, NoWait(), func() error {
+		panic(io.ErrUnexpectedEOF)
+	})
+
+	var p *errs.PanicError
+	assert.True(t, errors.As(e, &p))
+	assert.Equal(t, io.ErrUnexpectedEOF, p.Value)
+	assert.True(t, errors.Is(e, io.ErrUnexpectedEOF))
+	assert.NotEmpty(t, p.Stack())
+}
+
+func TestRetryWithManualClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := NewManualClock(start)
+	ctx := WithClock(context.Background(), clk)
+
+	attempts := 5
+	runs := make(chan time.Time)
+	done := make(chan error)
+
+	go func() {
+		run := 0
+		done <- Retry(ctx, attempts, ExponentialBackoff(time.Second, 4*time.Second), func() error {
+			run++
+			runs <- clk.Now()
+			return fmt.Errorf("run %d", run)
 		})
+	}()
+
+	assert.Equal(t, start, <-runs)
+	elapsed := time.Duration(0)
+	for _, d := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		clk.BlockUntil(1)
+		clk.Advance(d)
+		elapsed += d
+		assert.Equal(t, start.Add(elapsed), <-runs)
 	}
+
+	assert.EqualError(t, <-done, "run 5")
 }
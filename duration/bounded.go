@@ -0,0 +1,108 @@
+package duration
+
+import (
+	"fmt"
+	"time"
+)
+
+// Range bounds durations within [Min, Max], a Max of 0 means no upper bound
+type Range struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (r Range) String() string {
+	if r.Max == 0 {
+		return fmt.Sprintf("[%s, ∞)", Format(r.Min))
+	}
+	return fmt.Sprintf("[%s, %s]", Format(r.Min), Format(r.Max))
+}
+
+// Check returns an error naming the allowed range if d is out of it
+func (r Range) Check(d time.Duration) error {
+	if d < r.Min || (r.Max != 0 && d > r.Max) {
+		return fmt.Errorf("duration: %s is out of the allowed range %s", Format(d), r)
+	}
+	return nil
+}
+
+// Bounds gives the range of a Bounded duration, it is usually implemented by an empty struct
+type Bounds interface {
+	Range() Range
+}
+
+// Bounded is a Duration checked against the range given by B while being decoded, for example:
+//
+//	type timeoutRange struct{}
+//
+//	func (timeoutRange) Range() duration.Range {
+//		return duration.Range{Min: time.Second, Max: time.Minute}
+//	}
+//
+//	type Config struct {
+//		Timeout duration.Bounded[timeoutRange] `json:"timeout"`
+//	}
+type Bounded[B Bounds] struct {
+	Duration
+}
+
+// NonNegative rejects negative durations while being decoded
+type NonNegative = Bounded[nonNegative]
+
+// Positive rejects zero or negative durations while being decoded
+type Positive = Bounded[positive]
+
+type nonNegative struct{}
+
+func (nonNegative) Range() Range {
+	return Range{}
+}
+
+type positive struct{}
+
+func (positive) Range() Range {
+	return Range{Min: time.Nanosecond}
+}
+
+// Check checks the duration against its range
+func (d Bounded[B]) Check() error {
+	var b B
+	return b.Range().Check(d.Duration.Duration)
+}
+
+func (d *Bounded[B]) UnmarshalJSON(b []byte) error {
+	return d.decode(func(tmp *Duration) error { return tmp.UnmarshalJSON(b) })
+}
+
+func (d *Bounded[B]) UnmarshalText(b []byte) error {
+	return d.decode(func(tmp *Duration) error { return tmp.UnmarshalText(b) })
+}
+
+func (d *Bounded[B]) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return d.decode(func(tmp *Duration) error { return tmp.UnmarshalYAML(unmarshal) })
+}
+
+func (d *Bounded[B]) UnmarshalTOML(v interface{}) error {
+	return d.decode(func(tmp *Duration) error { return tmp.UnmarshalTOML(v) })
+}
+
+func (d *Bounded[B]) Set(s string) error {
+	return d.decode(func(tmp *Duration) error { return tmp.Set(s) })
+}
+
+func (d *Bounded[B]) Scan(src interface{}) error {
+	return d.decode(func(tmp *Duration) error { return tmp.Scan(src) })
+}
+
+// decode decodes into a copy, which replaces the duration only if it is in range
+func (d *Bounded[B]) decode(f func(tmp *Duration) error) error {
+	tmp := Bounded[B]{d.Duration}
+	if e := f(&tmp.Duration); e != nil {
+		return e
+	}
+	if e := tmp.Check(); e != nil {
+		return e
+	}
+	*d = tmp
+	return nil
+}
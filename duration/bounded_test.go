@@ -0,0 +1,55 @@
+package duration
+
+import (
+	"encoding/json"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type timeoutRange struct{}
+
+func (timeoutRange) Range() Range {
+	return Range{Min: time.Second, Max: time.Minute}
+}
+
+func TestBounded(t *testing.T) {
+	var conf struct {
+		Timeout Bounded[timeoutRange] `json:"timeout" yaml:"timeout"`
+		Delay   NonNegative           `json:"delay" yaml:"delay"`
+		Period  Positive              `json:"period" yaml:"period"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"timeout": "30s", "delay": "0s", "period": "1h"}`), &conf))
+	assert.Equal(t, 30*time.Second, conf.Timeout.Std())
+	assert.Equal(t, time.Hour, conf.Period.Std())
+
+	b, e := json.Marshal(conf)
+	require.NoError(t, e)
+	assert.JSONEq(t, `{"timeout": "30s", "delay": "0s", "period": "1h"}`, string(b))
+
+	e = json.Unmarshal([]byte(`{"timeout": "2m"}`), &conf)
+	assert.EqualError(t, e, "duration: 2m is out of the allowed range [1s, 1m]")
+	// rejected values leave the old one
+	assert.Equal(t, 30*time.Second, conf.Timeout.Std())
+	assert.Error(t, conf.Timeout.Set("0s"))
+	assert.Error(t, conf.Timeout.Scan("1h"))
+	assert.Equal(t, 30*time.Second, conf.Timeout.Std())
+
+	e = json.Unmarshal([]byte(`{"delay": "-1s"}`), &conf)
+	assert.EqualError(t, e, "duration: -1s is out of the allowed range [0s, ∞)")
+
+	e = yaml.Unmarshal([]byte("period: 0s\n"), &conf)
+	assert.EqualError(t, e, "duration: 0s is out of the allowed range [1ns, ∞)")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&conf.Timeout, "timeout", "timeout")
+	assert.Error(t, fs.Parse([]string{"-timeout", "500ms"}))
+
+	conf.Timeout.Duration.Duration = time.Hour
+	assert.Error(t, conf.Timeout.Check())
+}
@@ -1,34 +1,142 @@
 package duration
 
 import (
+	"bytes"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 )
 
+// Duration wraps time.Duration to be encoded as a human readable string like "5s" or "7d".
+// Strings are parsed by Parse and formatted by Format.
+// It implements json, text, yaml and toml (un)marshalers, flag.Value, sql.Scanner and driver.Valuer.
+// Bare numbers are decoded as nanoseconds, use a unit type like Seconds for other units,
+// or Strict to reject them.
 type Duration struct {
 	time.Duration
 }
 
 func (d *Duration) UnmarshalJSON(b []byte) error {
+	return d.decodeJSON(b, time.Nanosecond)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	return d.Set(string(b))
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalYAML implements the unmarshaler of gopkg.in/yaml.v2, which yaml.v3 supports as well
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return d.decodeYAML(unmarshal, time.Nanosecond)
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// UnmarshalTOML implements the unmarshaler of github.com/BurntSushi/toml,
+// other toml packages fall back to UnmarshalText
+func (d *Duration) UnmarshalTOML(v interface{}) error {
+	return d.set(v, time.Nanosecond)
+}
+
+// String formats the duration in the most compact form, see Format
+func (d Duration) String() string {
+	return Format(d.Duration)
+}
+
+// Set implements flag.Value
+func (d *Duration) Set(s string) error {
+	tmp, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = tmp
+	return nil
+}
+
+// Type implements pflag.Value
+func (d *Duration) Type() string {
+	return "duration"
+}
+
+// Scan implements sql.Scanner, it accepts nanoseconds as numbers, or strings like "5s"
+func (d *Duration) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		d.Duration = 0
+		return nil
+	case []byte:
+		return d.Set(string(value))
+	default:
+		return d.set(value, time.Nanosecond)
+	}
+}
+
+// Value implements driver.Valuer, it stores nanoseconds
+func (d Duration) Value() (driver.Value, error) {
+	return int64(d.Duration), nil
+}
+
+func (d *Duration) decodeJSON(b []byte, unit time.Duration) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	return d.set(v, unit)
+}
+
+func (d *Duration) decodeYAML(unmarshal func(interface{}) error, unit time.Duration) error {
 	var v interface{}
-	if err := json.Unmarshal(b, &v); err != nil {
+	if err := unmarshal(&v); err != nil {
 		return err
 	}
+	return d.set(v, unit)
+}
+
+// set decodes a string with Parse, or a number in unit, numbers are rejected if unit is 0
+func (d *Duration) set(v interface{}, unit time.Duration) error {
+	var number string
 	switch value := v.(type) {
-	case float64:
-		d.Duration = time.Duration(value)
-		return nil
 	case string:
-		tmp, err := time.ParseDuration(value)
-		if err != nil {
-			return err
-		}
-		d.Duration = tmp
-		return nil
+		return d.Set(value)
+	case json.Number:
+		number = value.String()
+	case float64:
+		number = strconv.FormatFloat(value, 'f', -1, 64)
+	case int:
+		number = strconv.Itoa(value)
+	case int64:
+		number = strconv.FormatInt(value, 10)
+	case uint64:
+		number = strconv.FormatUint(value, 10)
 	default:
 		return errors.New("invalid duration")
 	}
+
+	if unit == 0 {
+		return fmt.Errorf("duration: bare number %s is ambiguous, use a string with a unit like \"%ss\" or \"%sms\"", number, number, number)
+	}
+
+	tmp, err := parseNumber(number, unit)
+	if err != nil {
+		return err
+	}
+	d.Duration = tmp
+	return nil
 }
 
 func (d Duration) Std() time.Duration {
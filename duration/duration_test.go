@@ -0,0 +1,105 @@
+package duration
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type config struct {
+	Timeout  Duration `json:"timeout" yaml:"timeout" toml:"timeout"`
+	Interval Duration `json:"interval" yaml:"interval" toml:"interval"`
+}
+
+var want = config{
+	Timeout:  Duration{5 * time.Second},
+	Interval: Duration{90 * time.Minute},
+}
+
+func TestJSON(t *testing.T) {
+	b, e := json.Marshal(want)
+	require.NoError(t, e)
+	assert.JSONEq(t, `{"timeout":"5s","interval":"1h30m"}`, string(b))
+
+	var got config
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, want, got)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"timeout":5000000000}`), &got))
+	assert.Equal(t, 5*time.Second, got.Timeout.Std())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"timeout":true}`), &got))
+	assert.Error(t, json.Unmarshal([]byte(`{"timeout":"5 parsecs"}`), &got))
+}
+
+func TestText(t *testing.T) {
+	b, e := want.Timeout.MarshalText()
+	require.NoError(t, e)
+	assert.Equal(t, "5s", string(b))
+
+	var got Duration
+	require.NoError(t, got.UnmarshalText(b))
+	assert.Equal(t, want.Timeout, got)
+}
+
+func TestYAML(t *testing.T) {
+	b, e := yaml.Marshal(want)
+	require.NoError(t, e)
+	assert.Equal(t, "timeout: 5s\ninterval: 1h30m\n", string(b))
+
+	var got config
+	require.NoError(t, yaml.Unmarshal(b, &got))
+	assert.Equal(t, want, got)
+
+	require.NoError(t, yaml.Unmarshal([]byte("timeout: 5000000000\n"), &got))
+	assert.Equal(t, 5*time.Second, got.Timeout.Std())
+}
+
+func TestTOML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, toml.NewEncoder(&buf).Encode(want))
+	assert.Equal(t, "timeout = \"5s\"\ninterval = \"1h30m\"\n", buf.String())
+
+	var got config
+	_, e := toml.Decode(buf.String(), &got)
+	require.NoError(t, e)
+	assert.Equal(t, want, got)
+
+	_, e = toml.Decode("timeout = 5000000000\n", &got)
+	require.NoError(t, e)
+	assert.Equal(t, 5*time.Second, got.Timeout.Std())
+}
+
+func TestFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	got := Duration{time.Second}
+	fs.Var(&got, "timeout", "timeout")
+
+	assert.Equal(t, "1s", fs.Lookup("timeout").DefValue)
+	require.NoError(t, fs.Parse([]string{"-timeout", "5s"}))
+	assert.Equal(t, want.Timeout, got)
+	assert.Error(t, fs.Parse([]string{"-timeout", "5"}))
+}
+
+func TestSQL(t *testing.T) {
+	v, e := want.Interval.Value()
+	require.NoError(t, e)
+	assert.Equal(t, int64(90*time.Minute), v)
+
+	for _, src := range []interface{}{v, []byte("1h30m"), "1h30m", float64(90 * time.Minute)} {
+		var got Duration
+		require.NoError(t, got.Scan(src))
+		assert.Equal(t, want.Interval, got)
+	}
+
+	got := want.Interval
+	require.NoError(t, got.Scan(nil))
+	assert.Zero(t, got.Std())
+}
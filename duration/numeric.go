@@ -0,0 +1,133 @@
+package duration
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Milliseconds decodes bare numbers as milliseconds, strings with units work as in Duration
+type Milliseconds struct {
+	Duration
+}
+
+func (d *Milliseconds) UnmarshalJSON(b []byte) error {
+	return d.decodeJSON(b, time.Millisecond)
+}
+
+func (d *Milliseconds) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return d.decodeYAML(unmarshal, time.Millisecond)
+}
+
+func (d *Milliseconds) UnmarshalTOML(v interface{}) error {
+	return d.set(v, time.Millisecond)
+}
+
+// Seconds decodes bare numbers as seconds, strings with units work as in Duration
+type Seconds struct {
+	Duration
+}
+
+func (d *Seconds) UnmarshalJSON(b []byte) error {
+	return d.decodeJSON(b, time.Second)
+}
+
+func (d *Seconds) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return d.decodeYAML(unmarshal, time.Second)
+}
+
+func (d *Seconds) UnmarshalTOML(v interface{}) error {
+	return d.set(v, time.Second)
+}
+
+// Minutes decodes bare numbers as minutes, strings with units work as in Duration
+type Minutes struct {
+	Duration
+}
+
+func (d *Minutes) UnmarshalJSON(b []byte) error {
+	return d.decodeJSON(b, time.Minute)
+}
+
+func (d *Minutes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return d.decodeYAML(unmarshal, time.Minute)
+}
+
+func (d *Minutes) UnmarshalTOML(v interface{}) error {
+	return d.set(v, time.Minute)
+}
+
+// Hours decodes bare numbers as hours, strings with units work as in Duration
+type Hours struct {
+	Duration
+}
+
+func (d *Hours) UnmarshalJSON(b []byte) error {
+	return d.decodeJSON(b, time.Hour)
+}
+
+func (d *Hours) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return d.decodeYAML(unmarshal, time.Hour)
+}
+
+func (d *Hours) UnmarshalTOML(v interface{}) error {
+	return d.set(v, time.Hour)
+}
+
+// Strict rejects bare numbers, so a missing unit never silently means nanoseconds
+type Strict struct {
+	Duration
+}
+
+func (d *Strict) UnmarshalJSON(b []byte) error {
+	return d.decodeJSON(b, 0)
+}
+
+func (d *Strict) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return d.decodeYAML(unmarshal, 0)
+}
+
+func (d *Strict) UnmarshalTOML(v interface{}) error {
+	return d.set(v, 0)
+}
+
+// parseNumber parses a decimal number of units exactly, numbers in exponent form go through float64
+func parseNumber(s string, unit time.Duration) (time.Duration, error) {
+	orig := s
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	var d time.Duration
+	if strings.ContainsAny(s, "eE") {
+		f, e := strconv.ParseFloat(s, 64)
+		if e != nil {
+			return 0, fmt.Errorf("duration: invalid number %s: %w", orig, e)
+		}
+		f *= float64(unit)
+		if f >= math.MaxInt64 {
+			return 0, fmt.Errorf("duration: number %s overflows", orig)
+		}
+		d = time.Duration(f)
+	} else {
+		whole, frac, scale, rest, e := leadingNumber(s)
+		if e == nil && rest != "" {
+			e = errors.New("trailing characters")
+		}
+		if e == nil {
+			var u uint64
+			u, e = add(0, whole, frac, scale, unit, 1<<63-1)
+			d = time.Duration(u)
+		}
+		if e != nil {
+			return 0, fmt.Errorf("duration: invalid number %s: %w", orig, e)
+		}
+	}
+
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,68 @@
+package duration
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExactNanoseconds(t *testing.T) {
+	var d Duration
+	// not representable in a float64
+	require.NoError(t, json.Unmarshal([]byte(`9007199254740993`), &d))
+	assert.Equal(t, time.Duration(9007199254740993), d.Std())
+
+	require.NoError(t, json.Unmarshal([]byte(`-15`), &d))
+	assert.Equal(t, time.Duration(-15), d.Std())
+
+	assert.Error(t, json.Unmarshal([]byte(`9223372036854775808`), &d))
+}
+
+func TestUnits(t *testing.T) {
+	var conf struct {
+		Timeout  Seconds      `json:"timeout" yaml:"timeout" toml:"timeout"`
+		Interval Milliseconds `json:"interval" yaml:"interval" toml:"interval"`
+		Expiry   Hours        `json:"expiry" yaml:"expiry" toml:"expiry"`
+		Delay    Minutes      `json:"delay" yaml:"delay" toml:"delay"`
+	}
+	check := func() {
+		assert.Equal(t, 30*time.Second, conf.Timeout.Std())
+		assert.Equal(t, 1500*time.Microsecond, conf.Interval.Std())
+		assert.Equal(t, 2*time.Hour, conf.Expiry.Std())
+		assert.Equal(t, 5*time.Second, conf.Delay.Std())
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"timeout": 30, "interval": 1.5, "expiry": "2h", "delay": "5s"}`), &conf))
+	check()
+
+	b, e := json.Marshal(conf)
+	require.NoError(t, e)
+	assert.JSONEq(t, `{"timeout": "30s", "interval": "1.5ms", "expiry": "2h", "delay": "5s"}`, string(b))
+
+	require.NoError(t, yaml.Unmarshal([]byte("timeout: 30\ninterval: 1.5\nexpiry: 2\ndelay: 5s\n"), &conf))
+	check()
+
+	_, e = toml.Decode("timeout = 30\ninterval = 1.5\nexpiry = \"2h\"\ndelay = 0.08333333333333333\n", &conf)
+	require.NoError(t, e)
+	assert.InDelta(t, float64(5*time.Second), float64(conf.Delay.Std()), float64(time.Microsecond))
+}
+
+func TestStrict(t *testing.T) {
+	var conf struct {
+		Timeout Strict `json:"timeout" yaml:"timeout"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"timeout": "30s"}`), &conf))
+	assert.Equal(t, 30*time.Second, conf.Timeout.Std())
+
+	e := json.Unmarshal([]byte(`{"timeout": 30}`), &conf)
+	assert.EqualError(t, e, `duration: bare number 30 is ambiguous, use a string with a unit like "30s" or "30ms"`)
+
+	e = yaml.Unmarshal([]byte("timeout: 30\n"), &conf)
+	assert.Error(t, e)
+}
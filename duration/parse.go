@@ -0,0 +1,290 @@
+package duration
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Day  = 24 * time.Hour
+	Week = 7 * Day
+)
+
+var units = map[string]time.Duration{
+	"ns": time.Nanosecond, "nanosecond": time.Nanosecond, "nanoseconds": time.Nanosecond,
+	"us": time.Microsecond, "µs": time.Microsecond, "μs": time.Microsecond, "microsecond": time.Microsecond, "microseconds": time.Microsecond,
+	"ms": time.Millisecond, "millisecond": time.Millisecond, "milliseconds": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": Day, "day": Day, "days": Day,
+	"w": Week, "wk": Week, "wks": Week, "week": Week, "weeks": Week,
+}
+
+// units without a fixed length
+var ambiguousUnits = map[string]bool{
+	"mo": true, "mos": true, "month": true, "months": true,
+	"y": true, "yr": true, "yrs": true, "year": true, "years": true,
+}
+
+var errAmbiguous = errors.New("months and years vary in length, use days or weeks instead")
+
+// Parse parses a duration string. Besides the format of time.ParseDuration, it accepts
+// days and weeks like "7d" or "2w1d", ISO 8601 durations like "P1DT2H" or "PT0.5S",
+// and human friendly strings like "1 hour 30 minutes" or "2 days, 4 hours and 5 seconds".
+// Months and years are rejected since their length is ambiguous.
+func Parse(s string) (time.Duration, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("duration: empty string")
+	}
+
+	neg := false
+	if s[0] == '-' || s[0] == '+' {
+		neg = s[0] == '-'
+		s = strings.TrimSpace(s[1:])
+	}
+
+	// magnitudes are accumulated unsigned, so the most negative duration is accepted too
+	max := uint64(1<<63 - 1)
+	if neg {
+		max++
+	}
+
+	var u uint64
+	var e error
+	if s == "0" {
+		u = 0
+	} else if len(s) > 0 && (s[0] == 'P' || s[0] == 'p') {
+		u, e = parseISO(s, max)
+	} else {
+		u, e = parseHuman(s, max)
+	}
+	if e != nil {
+		return 0, fmt.Errorf("duration: invalid duration %q: %w", orig, e)
+	}
+
+	d := time.Duration(u)
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+func parseHuman(s string, max uint64) (uint64, error) {
+	var total uint64
+	for n := 0; ; n++ {
+		s = trimSeparators(s)
+		if s == "" {
+			if n == 0 {
+				return 0, errors.New("no components")
+			}
+			return total, nil
+		}
+
+		whole, frac, scale, rest, e := leadingNumber(s)
+		if e != nil {
+			return 0, e
+		}
+
+		rest = strings.TrimLeft(rest, " ")
+		i := 0
+		for i < len(rest) && !isDigit(rest[i]) && rest[i] != '.' && rest[i] != ' ' && rest[i] != ',' {
+			i++
+		}
+		name := strings.ToLower(rest[:i])
+		s = rest[i:]
+
+		if name == "" {
+			return 0, errors.New("missing unit")
+		}
+		if ambiguousUnits[name] {
+			return 0, fmt.Errorf("ambiguous unit %q: %w", name, errAmbiguous)
+		}
+		unit, ok := units[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit %q", name)
+		}
+
+		if total, e = add(total, whole, frac, scale, unit, max); e != nil {
+			return 0, e
+		}
+	}
+}
+
+func trimSeparators(s string) string {
+	for {
+		t := strings.TrimLeft(s, " ,")
+		if strings.HasPrefix(t, "and ") {
+			t = t[len("and "):]
+		}
+		if t == s {
+			return s
+		}
+		s = t
+	}
+}
+
+// parseISO parses ISO 8601 durations in the form of PnW or PnDTnHnMnS
+func parseISO(s string, max uint64) (uint64, error) {
+	s = strings.ToUpper(s[1:])
+	if s == "" {
+		return 0, errors.New("no components after P")
+	}
+
+	var total uint64
+	timePart := false
+	for s != "" {
+		if s[0] == 'T' {
+			if timePart {
+				return 0, errors.New("duplicate T designator")
+			}
+			timePart = true
+			s = s[1:]
+			if s == "" {
+				return 0, errors.New("no components after T")
+			}
+			continue
+		}
+
+		whole, frac, scale, rest, e := leadingNumber(strings.Replace(s, ",", ".", 1))
+		if e != nil {
+			return 0, e
+		}
+		if rest == "" {
+			return 0, errors.New("missing designator")
+		}
+
+		var unit time.Duration
+		switch designator := rest[0]; {
+		case designator == 'W' && !timePart:
+			unit = Week
+		case designator == 'D' && !timePart:
+			unit = Day
+		case designator == 'H' && timePart:
+			unit = time.Hour
+		case designator == 'M' && timePart:
+			unit = time.Minute
+		case designator == 'S' && timePart:
+			unit = time.Second
+		case (designator == 'Y' || designator == 'M') && !timePart:
+			return 0, fmt.Errorf("ambiguous designator %q: %w", designator, errAmbiguous)
+		default:
+			return 0, fmt.Errorf("unexpected designator %q", designator)
+		}
+		s = rest[1:]
+
+		if total, e = add(total, whole, frac, scale, unit, max); e != nil {
+			return 0, e
+		}
+	}
+	return total, nil
+}
+
+// leadingNumber consumes a decimal number like "12" or "1.5" from s
+func leadingNumber(s string) (whole, frac uint64, scale float64, rest string, e error) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	intPart := s[:i]
+
+	scale = 1
+	fracDigits := ""
+	if i < len(s) && s[i] == '.' {
+		j := i + 1
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+		fracDigits = s[i+1 : j]
+		i = j
+	}
+
+	if intPart == "" && fracDigits == "" {
+		return 0, 0, 0, "", fmt.Errorf("expected a number at %q", s)
+	}
+
+	if intPart != "" {
+		if whole, e = strconv.ParseUint(intPart, 10, 63); e != nil {
+			return 0, 0, 0, "", errors.New("number overflows")
+		}
+	}
+	// digits beyond nanosecond precision of the largest unit do not matter
+	if len(fracDigits) > 18 {
+		fracDigits = fracDigits[:18]
+	}
+	for _, c := range fracDigits {
+		frac = frac*10 + uint64(c-'0')
+		scale *= 10
+	}
+
+	return whole, frac, scale, s[i:], nil
+}
+
+// add adds whole.frac units to total, failing if the sum exceeds max
+func add(total, whole, frac uint64, scale float64, unit time.Duration, max uint64) (uint64, error) {
+	if whole > max/uint64(unit) {
+		return 0, errors.New("overflows")
+	}
+	v := whole * uint64(unit)
+	if frac > 0 {
+		v += uint64(float64(frac) * (float64(unit) / scale))
+		if v > max {
+			return 0, errors.New("overflows")
+		}
+	}
+	if total > max-v {
+		return 0, errors.New("overflows")
+	}
+	return total + v, nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// Format formats d in the most compact form Parse accepts, like "1w2d", "1h30m" or "1.5s".
+// Durations under a second are formatted like time.Duration.String.
+func Format(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+
+	var b strings.Builder
+	u := uint64(d)
+	if d < 0 {
+		b.WriteByte('-')
+		u = -u
+	}
+	if u < uint64(time.Second) {
+		b.WriteString(time.Duration(u).String())
+		return b.String()
+	}
+
+	for _, unit := range []struct {
+		name string
+		size time.Duration
+	}{
+		{"w", Week}, {"d", Day}, {"h", time.Hour}, {"m", time.Minute},
+	} {
+		if n := u / uint64(unit.size); n > 0 {
+			b.WriteString(strconv.FormatUint(n, 10))
+			b.WriteString(unit.name)
+			u %= uint64(unit.size)
+		}
+	}
+
+	if u > 0 {
+		b.WriteString(strconv.FormatUint(u/uint64(time.Second), 10))
+		if ns := u % uint64(time.Second); ns > 0 {
+			b.WriteByte('.')
+			b.WriteString(strings.TrimRight(fmt.Sprintf("%09d", ns), "0"))
+		}
+		b.WriteByte('s')
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package duration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]time.Duration{
+		"0":                              0,
+		"5s":                             5 * time.Second,
+		"1h30m":                          90 * time.Minute,
+		"-1.5h":                          -90 * time.Minute,
+		"300ms":                          300 * time.Millisecond,
+		"7d":                             7 * Day,
+		"2w":                             2 * Week,
+		"1w2d3h":                         Week + 2*Day + 3*time.Hour,
+		"1.5d":                           36 * time.Hour,
+		"P1DT2H":                         Day + 2*time.Hour,
+		"PT30M":                          30 * time.Minute,
+		"P2W":                            2 * Week,
+		"PT0.5S":                         500 * time.Millisecond,
+		"PT1,5S":                         1500 * time.Millisecond,
+		"-P1D":                           -Day,
+		"1 hour 30 minutes":              90 * time.Minute,
+		"1h 30m":                         90 * time.Minute,
+		"2 days, 4 hours and 5 seconds":  2*Day + 4*time.Hour + 5*time.Second,
+		"  3 Weeks  ":                    3 * Week,
+		"1 day 1 hr 1 min 1 sec 1 ms":    Day + time.Hour + time.Minute + time.Second + time.Millisecond,
+		"2562047h47m16.854775807s":       1<<63 - 1,
+		"-2562047h47m16.854775808s":      -1 << 63,
+		"0.000000001s":                   time.Nanosecond,
+		"1µs":                            time.Microsecond,
+		"1 microsecond and 1 nanosecond": time.Microsecond + time.Nanosecond,
+		"P0D":                            0,
+	}
+
+	for s, want := range tests {
+		got, e := Parse(s)
+		if assert.NoError(t, e, s) {
+			assert.Equal(t, want, got, s)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                         "empty string",
+		"5":                        "missing unit",
+		"5 parsecs":                `unknown unit "parsecs"`,
+		"1 month":                  `ambiguous unit "month"`,
+		"2y":                       `ambiguous unit "y"`,
+		"P1M":                      `ambiguous designator 'M'`,
+		"P1Y2D":                    `ambiguous designator 'Y'`,
+		"P":                        "no components after P",
+		"P1DT":                     "no components after T",
+		"PT1D":                     `unexpected designator 'D'`,
+		"P1H":                      `unexpected designator 'H'`,
+		"P1":                       "missing designator",
+		"h":                        "expected a number",
+		"9999999999999999w":        "overflows",
+		"2562047h48m":              "overflows",
+		"2562047h47m16.854775808s": "overflows",
+		"-":                        "no components",
+		"+":                        "no components",
+		",":                        "no components",
+		" , ":                      "no components",
+		"and , ":                   "no components",
+	}
+
+	for s, want := range tests {
+		_, e := Parse(s)
+		if assert.Error(t, e, s) {
+			assert.Contains(t, e.Error(), want, s)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                                  "0s",
+		5 * time.Second:                    "5s",
+		90 * time.Minute:                   "1h30m",
+		-90 * time.Minute:                  "-1h30m",
+		36 * time.Hour:                     "1d12h",
+		14 * Day:                           "2w",
+		Week + Day + time.Second:           "1w1d1s",
+		1500 * time.Millisecond:            "1.5s",
+		time.Minute + 250*time.Millisecond: "1m0.25s",
+		250 * time.Millisecond:             "250ms",
+		time.Microsecond + time.Nanosecond: "1.001µs",
+		1<<63 - 1:                          "15250w1d23h47m16.854775807s",
+		-1 << 63:                           "-15250w1d23h47m16.854775808s",
+	}
+
+	for d, want := range tests {
+		assert.Equal(t, want, Format(d))
+		back, e := Parse(want)
+		assert.NoError(t, e, want)
+		assert.Equal(t, d, back, want)
+	}
+}
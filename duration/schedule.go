@@ -0,0 +1,520 @@
+package duration
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/supremind/pkg/clock"
+)
+
+// Schedule is a recurring schedule parsed from a cron expression, it accepts
+//   - standard 5 fields: minute hour day-of-month month day-of-week, like "0 2 * * MON-FRI"
+//   - 6 fields with seconds first: second minute hour day-of-month month day-of-week
+//   - descriptors: @yearly (or @annually), @monthly, @weekly, @daily (or @midnight), @hourly
+//   - intervals: "@every 5m", in any format Parse accepts
+//
+// Fields accept "*", "?" (day fields only), lists, ranges, steps, and names like JAN or MON.
+// If both day fields are restricted, a day matching either one is fired, as in cron.
+// Expressions are evaluated in UTC, unless prefixed with a time zone like "CRON_TZ=Asia/Shanghai 0 2 * * *".
+// Fire times in wall clock hours skipped by daylight saving changes are skipped too,
+// and expressions with fixed hours fire once in hours repeated when clocks are set back.
+type Schedule struct {
+	expr string
+	loc  *time.Location
+
+	// bit i is set if the value i is allowed
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool
+
+	every time.Duration
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField = cronField{name: "second", min: 0, max: 59}
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday as well
+	dowField = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseSchedule parses a cron expression or descriptor, see Schedule
+func ParseSchedule(expr string) (Schedule, error) {
+	s := Schedule{expr: strings.TrimSpace(expr), loc: time.UTC}
+	spec := s.expr
+
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(spec, prefix) {
+			i := strings.IndexAny(spec, " \t")
+			if i < 0 {
+				return Schedule{}, fmt.Errorf("schedule: missing expression after time zone in %q", expr)
+			}
+			loc, e := time.LoadLocation(spec[len(prefix):i])
+			if e != nil {
+				return Schedule{}, fmt.Errorf("schedule: invalid time zone in %q: %w", expr, e)
+			}
+			s.loc = loc
+			spec = strings.TrimSpace(spec[i:])
+			break
+		}
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		every, e := Parse(strings.TrimPrefix(spec, "@every "))
+		if e != nil {
+			return Schedule{}, fmt.Errorf("schedule: invalid interval in %q: %w", expr, e)
+		}
+		if every <= 0 {
+			return Schedule{}, fmt.Errorf("schedule: interval must be positive in %q", expr)
+		}
+		s.every = every
+		return s, nil
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		full, ok := descriptors[strings.ToLower(spec)]
+		if !ok {
+			return Schedule{}, fmt.Errorf("schedule: unknown descriptor in %q", expr)
+		}
+		spec = full
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return Schedule{}, fmt.Errorf("schedule: expected 5 or 6 fields in %q, got %d", expr, len(fields))
+	}
+
+	var e error
+	for i, target := range []struct {
+		field cronField
+		bits  *uint64
+		star  *bool
+	}{
+		{secondField, &s.second, nil},
+		{minuteField, &s.minute, nil},
+		{hourField, &s.hour, nil},
+		{domField, &s.dom, &s.domStar},
+		{monthField, &s.month, nil},
+		{dowField, &s.dow, &s.dowStar},
+	} {
+		var star bool
+		*target.bits, star, e = target.field.parse(fields[i], target.star != nil)
+		if e != nil {
+			return Schedule{}, fmt.Errorf("schedule: invalid %s in %q: %w", target.field.name, expr, e)
+		}
+		if target.star != nil {
+			*target.star = star
+		}
+	}
+
+	// Sunday is 0 or 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+// MustParseSchedule is like ParseSchedule but panics on errors, it is meant for constant expressions
+func MustParseSchedule(expr string) Schedule {
+	s, e := ParseSchedule(expr)
+	if e != nil {
+		panic(e)
+	}
+	return s
+}
+
+func (f cronField) parse(s string, questionMark bool) (bits uint64, star bool, e error) {
+	for _, part := range strings.Split(s, ",") {
+		b, st, e := f.parsePart(part, questionMark)
+		if e != nil {
+			return 0, false, e
+		}
+		bits |= b
+		star = star || st
+	}
+	return bits, star, nil
+}
+
+func (f cronField) parsePart(s string, questionMark bool) (bits uint64, star bool, e error) {
+	rangeExpr, stepExpr := s, ""
+	if i := strings.Index(s, "/"); i >= 0 {
+		rangeExpr, stepExpr = s[:i], s[i+1:]
+	}
+
+	lo, hi := f.min, f.max
+	switch {
+	case rangeExpr == "*" || (questionMark && rangeExpr == "?"):
+		star = stepExpr == ""
+	default:
+		bounds := strings.SplitN(rangeExpr, "-", 2)
+		if lo, e = f.value(bounds[0]); e != nil {
+			return 0, false, e
+		}
+		hi = lo
+		if len(bounds) == 2 {
+			if hi, e = f.value(bounds[1]); e != nil {
+				return 0, false, e
+			}
+		} else if stepExpr != "" {
+			// "a/n" runs from a to the max
+			hi = f.max
+		}
+	}
+
+	step := 1
+	if stepExpr != "" {
+		if step, e = strconv.Atoi(stepExpr); e != nil || step <= 0 {
+			return 0, false, fmt.Errorf("invalid step %q", stepExpr)
+		}
+	}
+	if lo > hi {
+		return 0, false, fmt.Errorf("invalid range %q", rangeExpr)
+	}
+
+	for i := lo; i <= hi; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits, star, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, e := strconv.Atoi(s)
+	if e != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// searchYears bounds searching for fire times, so impossible dates like Feb 30 end up with no time
+const searchYears = 5
+
+// Next returns the first fire time strictly after t, or the zero time if there is none
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	if s.loc == nil {
+		return time.Time{}
+	}
+
+	for {
+		t = s.next(t)
+		if t.IsZero() || !s.repeated(t) {
+			return t
+		}
+	}
+}
+
+// Prev returns the last fire time strictly before t, or the zero time if there is none
+func (s Schedule) Prev(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(-s.every)
+	}
+	if s.loc == nil {
+		return time.Time{}
+	}
+
+	for {
+		t = s.prev(t)
+		if t.IsZero() || !s.repeated(t) {
+			return t
+		}
+	}
+}
+
+const allHours = 1<<24 - 1
+
+// repeated tells if t is the second occurrence of its wall clock time, after clocks were set back.
+// Expressions with fixed hours fire only at the first occurrence, like cron does.
+func (s Schedule) repeated(t time.Time) bool {
+	if s.hour&allHours == allHours {
+		return false
+	}
+
+	_, offset := t.Zone()
+	_, before := t.Add(-3 * time.Hour).Zone()
+	if before <= offset {
+		return false
+	}
+
+	first := t.Add(-time.Duration(before-offset) * time.Second)
+	_, firstOffset := first.Zone()
+	return firstOffset == before && sameWallClock(first, t)
+}
+
+func sameWallClock(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd && a.Hour() == b.Hour() && a.Minute() == b.Minute() && a.Second() == b.Second()
+}
+
+func (s Schedule) next(t time.Time) time.Time {
+	orig := t
+	t = t.In(s.loc).Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + searchYears
+
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+
+	for !s.matchMonth(t) {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.matchDay(t) {
+		month := t.Month()
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		if t.Month() != month {
+			goto wrap
+		}
+	}
+
+	for !s.matchHour(t) {
+		day := t.Day()
+		t = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
+		if t.Day() != day {
+			goto wrap
+		}
+	}
+
+	for !s.matchMinute(t) {
+		hour := t.Hour()
+		t = t.Add(time.Minute - time.Duration(t.Second())*time.Second)
+		if t.Hour() != hour {
+			goto wrap
+		}
+	}
+
+	for !s.matchSecond(t) {
+		minute := t.Minute()
+		t = t.Add(time.Second)
+		if t.Minute() != minute {
+			goto wrap
+		}
+	}
+
+	if !t.After(orig) {
+		return time.Time{}
+	}
+	return t
+}
+
+func (s Schedule) prev(t time.Time) time.Time {
+	orig := t
+	t = t.In(s.loc)
+	if tr := t.Truncate(time.Second); tr.Equal(t) {
+		t = t.Add(-time.Second)
+	} else {
+		t = tr
+	}
+	limit := t.Year() - searchYears
+
+wrap:
+	if t.Year() < limit {
+		return time.Time{}
+	}
+
+	for !s.matchMonth(t) {
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, s.loc).Add(-time.Second)
+		if t.Month() == time.December {
+			goto wrap
+		}
+	}
+
+	for !s.matchDay(t) {
+		month := t.Month()
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.loc).Add(-time.Second)
+		if t.Month() != month {
+			goto wrap
+		}
+	}
+
+	for !s.matchHour(t) {
+		day := t.Day()
+		t = t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second()+1)*time.Second)
+		if t.Day() != day {
+			goto wrap
+		}
+	}
+
+	for !s.matchMinute(t) {
+		hour := t.Hour()
+		t = t.Add(-time.Duration(t.Second()+1) * time.Second)
+		if t.Hour() != hour {
+			goto wrap
+		}
+	}
+
+	for !s.matchSecond(t) {
+		minute := t.Minute()
+		t = t.Add(-time.Second)
+		if t.Minute() != minute {
+			goto wrap
+		}
+	}
+
+	if !t.Before(orig) {
+		return time.Time{}
+	}
+	return t
+}
+
+func (s Schedule) matchMonth(t time.Time) bool {
+	return s.month&(1<<uint(t.Month())) != 0
+}
+
+func (s Schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (s Schedule) matchHour(t time.Time) bool {
+	return s.hour&(1<<uint(t.Hour())) != 0
+}
+
+func (s Schedule) matchMinute(t time.Time) bool {
+	return s.minute&(1<<uint(t.Minute())) != 0
+}
+
+func (s Schedule) matchSecond(t time.Time) bool {
+	return s.second&(1<<uint(t.Second())) != 0
+}
+
+// IsZero tells if the schedule is empty, an empty schedule never fires
+func (s Schedule) IsZero() bool {
+	return s.expr == ""
+}
+
+// String returns the expression the schedule was parsed from
+func (s Schedule) String() string {
+	return s.expr
+}
+
+func (s *Schedule) UnmarshalJSON(b []byte) error {
+	var expr string
+	if e := json.Unmarshal(b, &expr); e != nil {
+		return fmt.Errorf("schedule: expected a string: %w", e)
+	}
+	return s.UnmarshalText([]byte(expr))
+}
+
+func (s Schedule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.expr)
+}
+
+func (s *Schedule) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*s = Schedule{}
+		return nil
+	}
+	parsed, e := ParseSchedule(string(b))
+	if e != nil {
+		return e
+	}
+	*s = parsed
+	return nil
+}
+
+func (s Schedule) MarshalText() ([]byte, error) {
+	return []byte(s.expr), nil
+}
+
+// Ticker delivers fire times of a Schedule on its channel, like time.Ticker.
+// Fire times are dropped if the receiver falls behind.
+type Ticker struct {
+	C <-chan time.Time
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewTicker starts a Ticker for the schedule, driven by the clock, nil means clock.Real
+func (s Schedule) NewTicker(clk clock.Clock) *Ticker {
+	if clk == nil {
+		clk = clock.Real
+	}
+
+	c := make(chan time.Time, 1)
+	t := &Ticker{C: c, stop: make(chan struct{})}
+
+	go func() {
+		now := clk.Now()
+		for {
+			next := s.Next(now)
+			if next.IsZero() {
+				return
+			}
+
+			tm := clk.NewTimer(next.Sub(now))
+			select {
+			case <-t.stop:
+				tm.Stop()
+				return
+			case <-tm.C():
+			}
+
+			select {
+			case c <- next:
+			default:
+			}
+			now = next
+			if n := clk.Now(); n.After(now) {
+				now = n
+			}
+		}
+	}()
+
+	return t
+}
+
+// Stop turns off the ticker, no more times will be sent
+func (t *Ticker) Stop() {
+	t.once.Do(func() {
+		close(t.stop)
+	})
+}
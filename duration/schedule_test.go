@@ -0,0 +1,148 @@
+package duration
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supremind/pkg/clock"
+)
+
+func utc(s string) time.Time {
+	t, e := time.Parse(time.RFC3339, s)
+	if e != nil {
+		panic(e)
+	}
+	return t.UTC()
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		want []string
+	}{
+		{"0 2 * * MON-FRI", "2024-05-31T03:00:00Z", []string{"2024-06-03T02:00:00Z", "2024-06-04T02:00:00Z"}},
+		{"*/15 * * * * *", "2024-01-01T00:00:07.5Z", []string{"2024-01-01T00:00:15Z", "2024-01-01T00:00:30Z"}},
+		{"0 0 13 * FRI", "2024-09-01T00:00:00Z", []string{"2024-09-06T00:00:00Z", "2024-09-13T00:00:00Z", "2024-09-20T00:00:00Z"}},
+		{"0 0 29 2 *", "2021-01-01T00:00:00Z", []string{"2024-02-29T00:00:00Z", "2028-02-29T00:00:00Z"}},
+		{"0 12 * JAN,jul 7", "2024-01-01T00:00:00Z", []string{"2024-01-07T12:00:00Z", "2024-01-14T12:00:00Z"}},
+		{"5/20 8-10 1 * ?", "2024-01-01T09:50:00Z", []string{"2024-01-01T10:05:00Z", "2024-01-01T10:25:00Z", "2024-01-01T10:45:00Z", "2024-02-01T08:05:00Z"}},
+		{"@hourly", "2024-01-01T00:00:00Z", []string{"2024-01-01T01:00:00Z", "2024-01-01T02:00:00Z"}},
+		{"@weekly", "2024-01-01T00:00:00Z", []string{"2024-01-07T00:00:00Z", "2024-01-14T00:00:00Z"}},
+		{"@yearly", "2024-01-01T00:00:00Z", []string{"2025-01-01T00:00:00Z"}},
+		{"@every 1h30m", "2024-01-01T00:00:00Z", []string{"2024-01-01T01:30:00Z", "2024-01-01T03:00:00Z"}},
+		{"CRON_TZ=Asia/Shanghai 0 2 * * *", "2024-01-01T00:00:00Z", []string{"2024-01-01T18:00:00Z", "2024-01-02T18:00:00Z"}},
+		{"0 0 30 2 *", "2024-01-01T00:00:00Z", []string{"0001-01-01T00:00:00Z"}},
+	}
+
+	for _, test := range tests {
+		s, e := ParseSchedule(test.expr)
+		require.NoError(t, e, test.expr)
+
+		from := utc(test.from)
+		for _, want := range test.want {
+			next := s.Next(from)
+			assert.Equal(t, utc(want), next.UTC(), test.expr)
+			if next.IsZero() {
+				break
+			}
+
+			if s.every == 0 {
+				assert.Equal(t, next, s.Prev(next.Add(time.Second)), test.expr)
+			}
+			from = next
+		}
+	}
+}
+
+func TestScheduleDST(t *testing.T) {
+	// clocks in New York spring forward at 2024-03-10 02:00, and fall back at 2024-11-03 02:00
+	tests := []struct {
+		expr string
+		from string
+		want []string
+	}{
+		// 02:30 does not exist on Mar 10
+		{"CRON_TZ=America/New_York 30 2 * * *", "2024-03-09T00:00:00Z", []string{"2024-03-09T07:30:00Z", "2024-03-11T06:30:00Z"}},
+		// 01:30 happens twice on Nov 3, fire once
+		{"CRON_TZ=America/New_York 30 1 * * *", "2024-11-02T12:00:00Z", []string{"2024-11-03T05:30:00Z", "2024-11-04T06:30:00Z"}},
+		// but every hour means every hour
+		{"CRON_TZ=America/New_York 0 * * * *", "2024-11-03T04:30:00Z", []string{"2024-11-03T05:00:00Z", "2024-11-03T06:00:00Z", "2024-11-03T07:00:00Z"}},
+	}
+
+	for _, test := range tests {
+		s, e := ParseSchedule(test.expr)
+		require.NoError(t, e, test.expr)
+
+		from := utc(test.from)
+		var got []string
+		for range test.want {
+			from = s.Next(from)
+			got = append(got, from.UTC().Format(time.RFC3339))
+		}
+		assert.Equal(t, test.want, got, test.expr)
+
+		var back []string
+		for range test.want {
+			back = append([]string{from.UTC().Format(time.RFC3339)}, back...)
+			from = s.Prev(from)
+		}
+		assert.Equal(t, test.want, back, test.expr)
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"? * * * *",
+		"* * * FOO *",
+		"@fortnightly",
+		"@every 1 month",
+		"@every -5m",
+		"CRON_TZ=Mars/Olympus 0 0 * * *",
+	} {
+		_, e := ParseSchedule(expr)
+		assert.Error(t, e, expr)
+	}
+}
+
+func TestScheduleJSON(t *testing.T) {
+	var conf struct {
+		Backup Schedule `json:"backup"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"backup": "0 2 * * MON-FRI"}`), &conf))
+	assert.Equal(t, utc("2024-06-03T02:00:00Z"), conf.Backup.Next(utc("2024-06-01T00:00:00Z")))
+
+	b, e := json.Marshal(conf)
+	require.NoError(t, e)
+	assert.JSONEq(t, `{"backup": "0 2 * * MON-FRI"}`, string(b))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"backup": "0 2 * *"}`), &conf))
+	assert.Error(t, json.Unmarshal([]byte(`{"backup": 5}`), &conf))
+}
+
+func TestScheduleTicker(t *testing.T) {
+	start := utc("2024-01-01T00:00:00Z")
+	clk := clock.NewManual(start)
+
+	tk := MustParseSchedule("*/10 * * * * *").NewTicker(clk)
+	defer tk.Stop()
+
+	for i := 1; i <= 3; i++ {
+		clk.BlockUntil(1)
+		clk.Advance(10 * time.Second)
+		assert.Equal(t, start.Add(time.Duration(i)*10*time.Second), (<-tk.C).UTC())
+	}
+
+	tk.Stop()
+	tk.Stop()
+	assert.Eventually(t, func() bool { return clk.Waiters() == 0 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,556 @@
+package duration
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeOfDay is a wall clock time within a day, 24:00 stands for the end of a day
+type TimeOfDay struct {
+	Hour, Minute, Second int
+}
+
+// ParseTimeOfDay parses times like "22:00" or "22:00:30"
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return TimeOfDay{}, fmt.Errorf("time of day: invalid time %q, expected hh:mm or hh:mm:ss", s)
+	}
+
+	var values [3]int
+	for i, p := range parts {
+		v, e := strconv.Atoi(p)
+		if e != nil || len(p) > 2 || v < 0 {
+			return TimeOfDay{}, fmt.Errorf("time of day: invalid time %q", s)
+		}
+		values[i] = v
+	}
+
+	t := TimeOfDay{Hour: values[0], Minute: values[1], Second: values[2]}
+	if !t.valid() {
+		return TimeOfDay{}, fmt.Errorf("time of day: %q is out of range", s)
+	}
+	return t, nil
+}
+
+func (t TimeOfDay) valid() bool {
+	return t.Hour >= 0 && t.Minute >= 0 && t.Second >= 0 && t.Minute <= 59 && t.Second <= 59 &&
+		(t.Hour < 24 || t.Hour == 24 && t.Minute == 0 && t.Second == 0)
+}
+
+func (t TimeOfDay) String() string {
+	if t.Second != 0 {
+		return fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+	}
+	return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+}
+
+// Since returns the wall clock duration since midnight
+func (t TimeOfDay) Since() time.Duration {
+	return time.Duration(t.Hour)*time.Hour + time.Duration(t.Minute)*time.Minute + time.Duration(t.Second)*time.Second
+}
+
+// On returns the time of day on the date in loc.
+// If the time happens twice because clocks are set back, the earlier one is returned,
+// if it is skipped because clocks spring forward, the time is pushed forward by the gap,
+// so 02:30 is 03:30 on the day clocks jump from 02:00 to 03:00.
+func (t TimeOfDay) On(year int, month time.Month, day int, loc *time.Location) time.Time {
+	return wallTime(year, month, day, t, loc, false)
+}
+
+func (t *TimeOfDay) UnmarshalJSON(b []byte) error {
+	var s string
+	if e := json.Unmarshal(b, &s); e != nil {
+		return fmt.Errorf("time of day: expected a string: %w", e)
+	}
+	return t.UnmarshalText([]byte(s))
+}
+
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *TimeOfDay) UnmarshalText(b []byte) error {
+	parsed, e := ParseTimeOfDay(string(b))
+	if e != nil {
+		return e
+	}
+	*t = parsed
+	return nil
+}
+
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// wallTime resolves a wall clock time in loc, picking the latest instant of an ambiguous time if late is set.
+// Skipped times are taken in the offset before the gap, which pushes them forward.
+func wallTime(year int, month time.Month, day int, tod TimeOfDay, loc *time.Location, late bool) time.Time {
+	t := time.Date(year, month, day, tod.Hour, tod.Minute, tod.Second, 0, loc)
+
+	_, before := t.Add(-12 * time.Hour).Zone()
+	_, after := t.Add(12 * time.Hour).Zone()
+	if before == after {
+		return t
+	}
+
+	// clocks changed around, check both offsets
+	wall := time.Date(year, month, day, tod.Hour, tod.Minute, tod.Second, 0, time.UTC)
+	var found []time.Time
+	for _, offset := range []int{before, after} {
+		u := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if _, o := u.Zone(); o == offset {
+			found = append(found, u)
+		}
+	}
+	if len(found) == 0 {
+		// time.Date resolves skipped times in either offset, not always forward
+		return wall.Add(-time.Duration(before) * time.Second).In(loc)
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Before(found[j]) })
+	if late {
+		return found[len(found)-1]
+	}
+	return found[0]
+}
+
+// Interval is a span of time in [Start, End)
+type Interval struct {
+	Start, End time.Time
+}
+
+// Contains tells if t is in [Start, End)
+func (i Interval) Contains(t time.Time) bool {
+	return !t.Before(i.Start) && t.Before(i.End)
+}
+
+// IsZero tells if the interval is empty
+func (i Interval) IsZero() bool {
+	return i.Start.IsZero() && i.End.IsZero()
+}
+
+// Window is a recurring window of wall clock time in a location, it is parsed from strings like
+//   - "22:00-04:00 Asia/Shanghai": every day, crossing midnight
+//   - "Mon-Fri 09:00-18:00 UTC": on some days of the week
+//   - "Sat 22:00-Sun 04:00 Asia/Shanghai": weekly, from one day to another
+//
+// Days are English names or their 3 letter abbreviations, the location defaults to UTC,
+// and an en dash works as well as a hyphen.
+// Windows follow wall clocks, so they get shorter or longer when daylight saving time changes.
+// An end equal to the start means a whole day, or a whole week for weekly windows.
+type Window struct {
+	Start, End TimeOfDay
+	// Days windows may start on, all days if empty
+	Days []time.Weekday
+	// EndAfter is the number of days between the start and the end
+	EndAfter int
+	Location *time.Location
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var dashes = regexp.MustCompile(`\s*[-–—]\s*`)
+
+// ParseWindow parses a window, see Window
+func ParseWindow(s string) (Window, error) {
+	w := Window{Location: time.UTC}
+	fields := strings.Fields(dashes.ReplaceAllString(strings.TrimSpace(s), "-"))
+
+	if n := len(fields); n > 1 && !strings.Contains(fields[n-1], ":") {
+		loc, e := time.LoadLocation(fields[n-1])
+		if e != nil {
+			return Window{}, fmt.Errorf("window: invalid location in %q: %w", s, e)
+		}
+		w.Location = loc
+		fields = fields[:n-1]
+	}
+
+	var e error
+	switch len(fields) {
+	case 1:
+		e = w.parseDaily(fields[0])
+	case 2:
+		if w.Days, e = parseDays(fields[0]); e == nil {
+			e = w.parseDaily(fields[1])
+		}
+	case 3:
+		e = w.parseWeekly(fields)
+	default:
+		e = fmt.Errorf("unexpected format")
+	}
+	if e != nil {
+		return Window{}, fmt.Errorf("window: invalid window %q: %w", s, e)
+	}
+	return w, nil
+}
+
+// MustParseWindow is like ParseWindow but panics on errors, it is meant for constant expressions
+func MustParseWindow(s string) Window {
+	w, e := ParseWindow(s)
+	if e != nil {
+		panic(e)
+	}
+	return w
+}
+
+func (w *Window) parseDaily(s string) error {
+	times := strings.Split(s, "-")
+	if len(times) != 2 {
+		return fmt.Errorf("expected a time range like 22:00-04:00, got %q", s)
+	}
+
+	var e error
+	if w.Start, e = ParseTimeOfDay(times[0]); e != nil {
+		return e
+	}
+	if w.End, e = ParseTimeOfDay(times[1]); e != nil {
+		return e
+	}
+
+	if w.End.Since() <= w.Start.Since() {
+		w.EndAfter = 1
+	}
+	return nil
+}
+
+// parseWeekly parses ["Sat", "22:00-Sun", "04:00"]
+func (w *Window) parseWeekly(fields []string) error {
+	startDay, ok := dayNames[strings.ToLower(fields[0])]
+	if !ok {
+		return fmt.Errorf("unknown day %q", fields[0])
+	}
+
+	middle := strings.Split(fields[1], "-")
+	if len(middle) != 2 {
+		return fmt.Errorf("expected a range like Sat 22:00-Sun 04:00")
+	}
+	endDay, ok := dayNames[strings.ToLower(middle[1])]
+	if !ok {
+		return fmt.Errorf("unknown day %q", middle[1])
+	}
+
+	var e error
+	if w.Start, e = ParseTimeOfDay(middle[0]); e != nil {
+		return e
+	}
+	if w.End, e = ParseTimeOfDay(fields[2]); e != nil {
+		return e
+	}
+
+	w.Days = []time.Weekday{startDay}
+	w.EndAfter = (int(endDay) - int(startDay) + 7) % 7
+	if w.EndAfter == 0 && w.End.Since() <= w.Start.Since() {
+		w.EndAfter = 7
+	}
+	return nil
+}
+
+// parseDays parses lists of days and ranges like "Mon-Fri,Sun"
+func parseDays(s string) ([]time.Weekday, error) {
+	var set [7]bool
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		from, ok := dayNames[strings.ToLower(bounds[0])]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", bounds[0])
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, ok = dayNames[strings.ToLower(bounds[1])]; !ok {
+				return nil, fmt.Errorf("unknown day %q", bounds[1])
+			}
+		}
+
+		for d := from; ; d = (d + 1) % 7 {
+			set[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+
+	var days []time.Weekday
+	for d, ok := range set {
+		if ok {
+			days = append(days, time.Weekday(d))
+		}
+	}
+	return days, nil
+}
+
+func (w Window) startsOn(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, day := range w.Days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// occurrence returns the window starting on the date, if it starts on that day
+func (w Window) occurrence(year int, month time.Month, day int) (Interval, bool) {
+	loc := w.location()
+	date := time.Date(year, month, day, 12, 0, 0, 0, loc)
+	if !w.startsOn(date.Weekday()) {
+		return Interval{}, false
+	}
+
+	end := date.AddDate(0, 0, w.EndAfter)
+	i := Interval{
+		Start: wallTime(year, month, day, w.Start, loc, false),
+		End:   wallTime(end.Year(), end.Month(), end.Day(), w.End, loc, true),
+	}
+	if !i.End.After(i.Start) {
+		return Interval{}, false
+	}
+	return i, true
+}
+
+func (w Window) location() *time.Location {
+	if w.Location == nil {
+		return time.UTC
+	}
+	return w.Location
+}
+
+// Intervals returns occurrences of the window overlapping [from, to) in order
+func (w Window) Intervals(from, to time.Time) []Interval {
+	var out []Interval
+
+	// a window lasts at most a week
+	date := from.In(w.location()).AddDate(0, 0, -(w.EndAfter + 1))
+	for !date.After(to) {
+		if i, ok := w.occurrence(date.Date()); ok && i.End.After(from) && i.Start.Before(to) {
+			out = append(out, i)
+		}
+		date = date.AddDate(0, 0, 1)
+	}
+	return out
+}
+
+// Contains tells if t falls inside an occurrence of the window
+func (w Window) Contains(t time.Time) bool {
+	return len(w.Intervals(t, t.Add(time.Nanosecond))) > 0
+}
+
+// Next returns the first occurrence of the window ending after t,
+// which is the current one if t is inside the window
+func (w Window) Next(t time.Time) Interval {
+	// every window starts at least once a week
+	is := w.Intervals(t, t.AddDate(0, 0, 8))
+	if len(is) == 0 {
+		return Interval{}
+	}
+	return is[0]
+}
+
+// String formats the window from its fields in the form ParseWindow accepts, see Window.
+// Windows which no expression describes, like ones over several days starting on more than one day,
+// are formatted approximately, MarshalText rejects them.
+func (w Window) String() string {
+	s, _ := w.format()
+	return s
+}
+
+// format formats the window, with an error if parsing the result would not give the same window
+func (w Window) format() (string, error) {
+	if w.isZero() {
+		return "", nil
+	}
+
+	var e error
+	if !w.Start.valid() || !w.End.valid() {
+		e = fmt.Errorf("window: invalid time of day in %s-%s", w.Start, w.End)
+	}
+	for _, d := range w.Days {
+		if d < time.Sunday || d > time.Saturday {
+			e = fmt.Errorf("window: invalid day %d", d)
+		}
+	}
+
+	var b strings.Builder
+	daily := 0
+	if w.End.Since() <= w.Start.Since() {
+		daily = 1
+	}
+	weekly := len(w.Days) == 1 && (w.EndAfter >= 1 && w.EndAfter <= 6 || w.EndAfter == 7 && daily == 1)
+	if w.EndAfter == daily || !weekly {
+		if e == nil && w.EndAfter != daily {
+			e = fmt.Errorf("window: no expression ends %d days after starting on %v", w.EndAfter, w.Days)
+		}
+		if len(w.Days) > 0 {
+			b.WriteString(formatDays(w.Days))
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%s-%s", w.Start, w.End)
+	} else {
+		end := (w.Days[0] + time.Weekday(w.EndAfter)) % 7
+		fmt.Fprintf(&b, "%s %s-%s %s", w.Days[0].String()[:3], w.Start, end.String()[:3], w.End)
+	}
+	b.WriteByte(' ')
+	b.WriteString(w.location().String())
+	return b.String(), e
+}
+
+func (w Window) isZero() bool {
+	return w.Start == TimeOfDay{} && w.End == TimeOfDay{} && len(w.Days) == 0 && w.EndAfter == 0 && w.Location == nil
+}
+
+// formatDays formats days like "Mon-Wed,Fri"
+func formatDays(days []time.Weekday) string {
+	var set [7]bool
+	for _, d := range days {
+		set[d%7] = true
+	}
+
+	var parts []string
+	for d := 0; d < 7; d++ {
+		if !set[d] {
+			continue
+		}
+		from := d
+		for d+1 < 7 && set[d+1] {
+			d++
+		}
+		part := time.Weekday(from).String()[:3]
+		if d > from {
+			part += "-" + time.Weekday(d).String()[:3]
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (w *Window) UnmarshalJSON(b []byte) error {
+	var s string
+	if e := json.Unmarshal(b, &s); e != nil {
+		return fmt.Errorf("window: expected a string: %w", e)
+	}
+	return w.UnmarshalText([]byte(s))
+}
+
+func (w Window) MarshalJSON() ([]byte, error) {
+	s, e := w.format()
+	if e != nil {
+		return nil, e
+	}
+	return json.Marshal(s)
+}
+
+func (w *Window) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*w = Window{}
+		return nil
+	}
+	parsed, e := ParseWindow(string(b))
+	if e != nil {
+		return e
+	}
+	*w = parsed
+	return nil
+}
+
+func (w Window) MarshalText() ([]byte, error) {
+	s, e := w.format()
+	if e != nil {
+		return nil, e
+	}
+	return []byte(s), nil
+}
+
+// WindowSet is a union of windows, overlapping or adjacent occurrences are merged.
+// It is decoded from a json array of window strings.
+type WindowSet []Window
+
+// mergeHorizon bounds merging of windows which are always open
+const mergeHorizon = 366 * 24 * time.Hour
+
+// Contains tells if t falls inside any of the windows
+func (ws WindowSet) Contains(t time.Time) bool {
+	for _, w := range ws {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Intervals returns merged occurrences of the windows overlapping [from, to) in order
+func (ws WindowSet) Intervals(from, to time.Time) []Interval {
+	var all []Interval
+	for _, w := range ws {
+		all = append(all, w.Intervals(from, to)...)
+	}
+	return mergeIntervals(all)
+}
+
+// Next returns the first merged occurrence ending after t, which is the current one if t is inside a window.
+// Occurrences chained for longer than a year are cut.
+func (ws WindowSet) Next(t time.Time) Interval {
+	var next Interval
+	for _, w := range ws {
+		i := w.Next(t)
+		if i.IsZero() {
+			continue
+		}
+		if next.IsZero() || i.Start.Before(next.Start) {
+			next = i
+		}
+	}
+	if next.IsZero() {
+		return next
+	}
+
+	// extend by windows overlapping or touching the end
+	limit := next.Start.Add(mergeHorizon)
+	for extended := true; extended && next.End.Before(limit); {
+		extended = false
+		for _, i := range ws.Intervals(next.End, next.End.Add(time.Nanosecond)) {
+			if i.End.After(next.End) {
+				next.End = i.End
+				extended = true
+			}
+		}
+	}
+	if next.End.After(limit) {
+		next.End = limit
+	}
+	return next
+}
+
+func mergeIntervals(is []Interval) []Interval {
+	if len(is) == 0 {
+		return nil
+	}
+
+	sort.Slice(is, func(i, j int) bool { return is[i].Start.Before(is[j].Start) })
+	out := []Interval{is[0]}
+	for _, i := range is[1:] {
+		last := &out[len(out)-1]
+		if i.Start.After(last.End) {
+			out = append(out, i)
+			continue
+		}
+		if i.End.After(last.End) {
+			last.End = i.End
+		}
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+package duration
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeOfDay(t *testing.T) {
+	tod, e := ParseTimeOfDay("22:05")
+	require.NoError(t, e)
+	assert.Equal(t, TimeOfDay{Hour: 22, Minute: 5}, tod)
+	assert.Equal(t, "22:05", tod.String())
+	assert.Equal(t, 22*time.Hour+5*time.Minute, tod.Since())
+
+	tod, e = ParseTimeOfDay("07:00:30")
+	require.NoError(t, e)
+	assert.Equal(t, "07:00:30", tod.String())
+
+	for _, s := range []string{"", "noon", "7", "25:00", "12:60", "24:01", "1:2:3:4", "-1:00", "001:00"} {
+		_, e := ParseTimeOfDay(s)
+		assert.Error(t, e, s)
+	}
+
+	b, e := json.Marshal(TimeOfDay{Hour: 24})
+	require.NoError(t, e)
+	assert.Equal(t, `"24:00"`, string(b))
+	require.NoError(t, json.Unmarshal(b, &tod))
+	assert.Equal(t, TimeOfDay{Hour: 24}, tod)
+}
+
+func TestWindow(t *testing.T) {
+	t.Run("daily crossing midnight", func(t *testing.T) {
+		w := MustParseWindow("22:00-04:00 Asia/Shanghai")
+		assert.True(t, w.Contains(utc("2024-01-01T15:00:00Z")))  // 23:00
+		assert.True(t, w.Contains(utc("2024-01-01T19:59:59Z")))  // 03:59:59
+		assert.False(t, w.Contains(utc("2024-01-01T20:00:00Z"))) // 04:00
+		assert.False(t, w.Contains(utc("2024-01-01T13:59:59Z"))) // 21:59:59
+
+		assert.Equal(t, Interval{Start: utc("2024-01-01T14:00:00Z"), End: utc("2024-01-01T20:00:00Z")}, utcInterval(w.Next(utc("2024-01-01T15:00:00Z"))))
+		assert.Equal(t, Interval{Start: utc("2024-01-02T14:00:00Z"), End: utc("2024-01-02T20:00:00Z")}, utcInterval(w.Next(utc("2024-01-01T20:00:00Z"))))
+	})
+
+	t.Run("weekly", func(t *testing.T) {
+		// 2024-06-01 is a Saturday
+		w := MustParseWindow("Sat 22:00–Sun 04:00 Asia/Shanghai")
+		assert.True(t, w.Contains(utc("2024-06-01T15:00:00Z")))  // Sat 23:00
+		assert.True(t, w.Contains(utc("2024-06-01T19:00:00Z")))  // Sun 03:00
+		assert.False(t, w.Contains(utc("2024-06-01T21:00:00Z"))) // Sun 05:00
+		assert.False(t, w.Contains(utc("2024-05-31T15:00:00Z"))) // Fri 23:00
+
+		assert.Equal(t, Interval{Start: utc("2024-06-08T14:00:00Z"), End: utc("2024-06-08T20:00:00Z")}, utcInterval(w.Next(utc("2024-06-03T00:00:00Z"))))
+	})
+
+	t.Run("days of week", func(t *testing.T) {
+		w := MustParseWindow("Mon-Fri 09:00 - 18:00")
+		assert.True(t, w.Contains(utc("2024-06-03T10:00:00Z")))  // Mon
+		assert.False(t, w.Contains(utc("2024-06-01T10:00:00Z"))) // Sat
+		assert.Equal(t, Interval{Start: utc("2024-06-03T09:00:00Z"), End: utc("2024-06-03T18:00:00Z")}, utcInterval(w.Next(utc("2024-06-01T10:00:00Z"))))
+
+		w = MustParseWindow("Fri-Sun 23:00-01:00 UTC")
+		assert.True(t, w.Contains(utc("2024-06-03T00:30:00Z")))  // Mon 00:30, started on Sun
+		assert.False(t, w.Contains(utc("2024-06-04T00:30:00Z"))) // Tue 00:30
+	})
+
+	t.Run("daylight saving", func(t *testing.T) {
+		// clocks in New York spring forward at 2024-03-10 02:00, and fall back at 2024-11-03 02:00
+		w := MustParseWindow("01:00-03:00 America/New_York")
+		assert.Equal(t, Interval{Start: utc("2024-03-10T06:00:00Z"), End: utc("2024-03-10T07:00:00Z")}, utcInterval(w.Next(utc("2024-03-10T05:00:00Z"))))
+
+		w = MustParseWindow("00:30-01:30 America/New_York")
+		assert.Equal(t, Interval{Start: utc("2024-11-03T04:30:00Z"), End: utc("2024-11-03T06:30:00Z")}, utcInterval(w.Next(utc("2024-11-03T04:00:00Z"))))
+		assert.True(t, w.Contains(utc("2024-11-03T06:15:00Z")))
+
+		w = MustParseWindow("01:30-02:30 America/New_York")
+		assert.Equal(t, Interval{Start: utc("2024-11-03T05:30:00Z"), End: utc("2024-11-03T07:30:00Z")}, utcInterval(w.Next(utc("2024-11-03T04:00:00Z"))))
+
+		// skipped wall clock times are pushed forward by the gap
+		w = MustParseWindow("02:00-02:45 America/New_York")
+		assert.Equal(t, Interval{Start: utc("2024-03-10T07:00:00Z"), End: utc("2024-03-10T07:45:00Z")}, utcInterval(w.Next(utc("2024-03-10T05:00:00Z"))))
+		w = MustParseWindow("01:00-02:30 America/New_York")
+		assert.Equal(t, Interval{Start: utc("2024-03-10T06:00:00Z"), End: utc("2024-03-10T07:30:00Z")}, utcInterval(w.Next(utc("2024-03-10T05:00:00Z"))))
+		ny, e := time.LoadLocation("America/New_York")
+		require.NoError(t, e)
+		assert.Equal(t, utc("2021-03-14T07:30:00Z"), TimeOfDay{Hour: 2, Minute: 30}.On(2021, time.March, 14, ny).UTC())
+	})
+
+	t.Run("literal", func(t *testing.T) {
+		shanghai, e := time.LoadLocation("Asia/Shanghai")
+		require.NoError(t, e)
+
+		for want, w := range map[string]Window{
+			"22:00-04:00 Asia/Shanghai":      {Start: TimeOfDay{Hour: 22}, End: TimeOfDay{Hour: 4}, EndAfter: 1, Location: shanghai},
+			"Mon-Wed,Fri 09:00-18:00:30 UTC": {Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 18, Second: 30}, Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Friday}},
+			"Sat 22:00-04:00 Asia/Shanghai":  {Start: TimeOfDay{Hour: 22}, End: TimeOfDay{Hour: 4}, Days: []time.Weekday{time.Saturday}, EndAfter: 1, Location: shanghai},
+			"Fri 09:00-Mon 09:00 UTC":        {Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 9}, Days: []time.Weekday{time.Friday}, EndAfter: 3},
+		} {
+			assert.Equal(t, want, w.String())
+
+			b, e := json.Marshal(w)
+			require.NoError(t, e)
+			var back Window
+			require.NoError(t, json.Unmarshal(b, &back))
+			assert.Equal(t, want, back.String())
+			assert.Equal(t, w.Start, back.Start)
+			assert.Equal(t, w.End, back.End)
+			assert.Equal(t, w.EndAfter, back.EndAfter)
+			assert.Equal(t, w.location(), back.Location)
+			assert.ElementsMatch(t, w.Days, back.Days)
+		}
+
+		b, e := json.Marshal(Window{})
+		require.NoError(t, e)
+		assert.Equal(t, `""`, string(b))
+
+		w := MustParseWindow("Sat 22:00–Sun 04:00 Asia/Shanghai")
+		w.Start = TimeOfDay{Hour: 21}
+		assert.Equal(t, "Sat 21:00-04:00 Asia/Shanghai", w.String())
+
+		for _, w := range []Window{
+			{Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 9}, Days: []time.Weekday{time.Monday, time.Friday}, EndAfter: 3},
+			{Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 18}, Days: []time.Weekday{time.Monday}, EndAfter: 7},
+			{Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 8}, Days: []time.Weekday{time.Monday}},
+			{Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 18}, EndAfter: 2},
+			{Start: TimeOfDay{Hour: 25}, End: TimeOfDay{Hour: 18}},
+			{Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 18}, Days: []time.Weekday{7}},
+		} {
+			_, e := w.MarshalText()
+			assert.Error(t, e, "%+v", w)
+			_, e = json.Marshal(w)
+			assert.Error(t, e, "%+v", w)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, s := range []string{
+			"",
+			"22:00",
+			"22:00-25:00",
+			"Someday 22:00-04:00",
+			"Sat 22:00-Someday 04:00",
+			"22:00-04:00 Mars/Olympus",
+			"Mon Tue 22:00 04:00 UTC",
+		} {
+			_, e := ParseWindow(s)
+			assert.Error(t, e, s)
+		}
+	})
+}
+
+func TestWindowSet(t *testing.T) {
+	var conf struct {
+		Blackout WindowSet `json:"blackout"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"blackout": ["Sat 22:00-Sun 04:00 UTC", "Sun 03:00-05:00 UTC", "Sun 05:00-06:00 UTC", "Wed 12:00-13:00 UTC"]}`), &conf))
+	ws := conf.Blackout
+
+	assert.True(t, ws.Contains(utc("2024-06-02T04:30:00Z")))
+	assert.True(t, ws.Contains(utc("2024-06-05T12:30:00Z")))
+	assert.False(t, ws.Contains(utc("2024-06-02T06:00:00Z")))
+
+	merged := Interval{Start: utc("2024-06-01T22:00:00Z"), End: utc("2024-06-02T06:00:00Z")}
+	assert.Equal(t, merged, utcInterval(ws.Next(utc("2024-05-31T00:00:00Z"))))
+	assert.Equal(t, merged, utcInterval(ws.Next(utc("2024-06-02T03:30:00Z"))))
+
+	assert.Equal(t, []Interval{
+		merged,
+		{Start: utc("2024-06-05T12:00:00Z"), End: utc("2024-06-05T13:00:00Z")},
+	}, utcIntervals(ws.Intervals(utc("2024-06-01T00:00:00Z"), utc("2024-06-08T00:00:00Z"))))
+
+	b, e := json.Marshal(conf)
+	require.NoError(t, e)
+	assert.JSONEq(t, `{"blackout": ["Sat 22:00-04:00 UTC", "Sun 03:00-05:00 UTC", "Sun 05:00-06:00 UTC", "Wed 12:00-13:00 UTC"]}`, string(b))
+
+	always := WindowSet{MustParseWindow("00:00-00:00")}
+	from := utc("2024-06-01T12:00:00Z")
+	next := always.Next(from)
+	assert.Equal(t, utc("2024-06-01T00:00:00Z"), next.Start.UTC())
+	assert.Equal(t, next.Start.Add(mergeHorizon), next.End)
+
+	assert.True(t, WindowSet{}.Next(from).IsZero())
+}
+
+func utcInterval(i Interval) Interval {
+	return Interval{Start: i.Start.UTC(), End: i.End.UTC()}
+}
+
+func utcIntervals(is []Interval) []Interval {
+	for k := range is {
+		is[k] = utcInterval(is[k])
+	}
+	return is
+}
@@ -0,0 +1,391 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Catalog registers sentinel errors with stable codes like "CFG-001",
+// default messages and their translations.
+// Packages usually declare sentinels in DefaultCatalog at init time,
+// and a generator program importing them could export the catalog with WriteMarkdown or WriteJSON.
+type Catalog struct {
+	mu        sync.RWMutex
+	sentinels map[string]*Sentinel
+}
+
+// DefaultCatalog is where Define registers sentinels
+var DefaultCatalog = NewCatalog()
+
+func NewCatalog() *Catalog {
+	return &Catalog{sentinels: make(map[string]*Sentinel)}
+}
+
+// Define registers a sentinel in DefaultCatalog, see Catalog.Define
+func Define(code, message string) *Sentinel {
+	return DefaultCatalog.Define(code, message)
+}
+
+// Define registers a sentinel error with a code and a default message.
+// Messages could refer to parameters like "{path}", which are filled by Sentinel.New.
+// It panics if the code is registered already, since sentinels are declared at init time.
+func (c *Catalog) Define(code, message string) *Sentinel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.sentinels[code]; ok {
+		panic(fmt.Sprintf("errs: error code %s is defined twice", code))
+	}
+
+	s := &Sentinel{
+		code:         code,
+		message:      message,
+		params:       placeholders(message),
+		translations: make(map[string]string),
+		catalog:      c,
+	}
+	c.sentinels[code] = s
+	return s
+}
+
+// Lookup finds a sentinel by its code
+func (c *Catalog) Lookup(code string) (*Sentinel, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s, ok := c.sentinels[code]
+	return s, ok
+}
+
+// Sentinels returns all registered sentinels ordered by code
+func (c *Catalog) Sentinels() []*Sentinel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make([]*Sentinel, 0, len(c.sentinels))
+	for _, s := range c.sentinels {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].code < out[j].code })
+	return out
+}
+
+// Locales returns all locales with translations in order
+func (c *Catalog) Locales() []string {
+	seen := make(map[string]bool)
+	for _, s := range c.Sentinels() {
+		for _, l := range s.Locales() {
+			seen[l] = true
+		}
+	}
+
+	out := make([]string, 0, len(seen))
+	for l := range seen {
+		out = append(out, l)
+	}
+	sort.Strings(out)
+	return out
+}
+
+type catalogEntry struct {
+	Code         string            `json:"code"`
+	Message      string            `json:"message"`
+	Params       []string          `json:"params,omitempty"`
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+// WriteJSON exports the catalog as a json array ordered by code
+func (c *Catalog) WriteJSON(w io.Writer) error {
+	entries := []catalogEntry{}
+	for _, s := range c.Sentinels() {
+		entries = append(entries, catalogEntry{
+			Code:         s.code,
+			Message:      s.message,
+			Params:       s.params,
+			Translations: s.translationsCopy(),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// WriteMarkdown exports the catalog as a markdown table ordered by code, a column for each locale
+func (c *Catalog) WriteMarkdown(w io.Writer) error {
+	locales := c.Locales()
+
+	var b strings.Builder
+	b.WriteString("| Code | Message |")
+	for _, l := range locales {
+		fmt.Fprintf(&b, " %s |", l)
+	}
+	b.WriteString("\n| --- | --- |")
+	for range locales {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+
+	for _, s := range c.Sentinels() {
+		fmt.Fprintf(&b, "| %s | %s |", markdownCell(s.code), markdownCell(s.message))
+		for _, l := range locales {
+			fmt.Fprintf(&b, " %s |", markdownCell(s.translation(l)))
+		}
+		b.WriteString("\n")
+	}
+
+	_, e := io.WriteString(w, b.String())
+	return e
+}
+
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// Sentinel is a registered error with a stable code.
+// Errors created by New or Wrap match it with errors.Is.
+type Sentinel struct {
+	code         string
+	errCode      Code
+	message      string
+	params       []string
+	catalog      *Catalog
+	translations map[string]string
+}
+
+// Translate adds a message template for a locale like "zh" or "zh-CN", and returns the sentinel to be chained.
+// It panics if the template refers to parameters unknown to the default message.
+func (s *Sentinel) Translate(locale, message string) *Sentinel {
+	for _, p := range placeholders(message) {
+		if !contains(s.params, p) {
+			panic(fmt.Sprintf("errs: translation %s of %s refers to unknown parameter %s", locale, s.code, p))
+		}
+	}
+
+	s.catalog.mu.Lock()
+	defer s.catalog.mu.Unlock()
+	s.translations[locale] = message
+	return s
+}
+
+// WithCode sets the Code of errors of the sentinel, and returns the sentinel to be chained.
+// CodeOf and HTTPStatus map them by it.
+func (s *Sentinel) WithCode(c Code) *Sentinel {
+	s.catalog.mu.Lock()
+	defer s.catalog.mu.Unlock()
+	s.errCode = c
+	return s
+}
+
+// Code returns the stable code
+func (s *Sentinel) Code() string {
+	return s.code
+}
+
+// ErrorCode returns the Code set by WithCode, empty if none
+func (s *Sentinel) ErrorCode() Code {
+	s.catalog.mu.RLock()
+	defer s.catalog.mu.RUnlock()
+	return s.errCode
+}
+
+// Error returns the code and the default message with parameters unfilled
+func (s *Sentinel) Error() string {
+	return s.code + ": " + s.message
+}
+
+// Locales returns locales the sentinel is translated into
+func (s *Sentinel) Locales() []string {
+	translations := s.translationsCopy()
+	out := make([]string, 0, len(translations))
+	for l := range translations {
+		out = append(out, l)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// New creates an error of the sentinel, with parameters given as key/value pairs.
+// A value left without a key is kept as the parameter "!BADKEY", and shows up in Error.
+func (s *Sentinel) New(kv ...interface{}) *CatalogError {
+	return &CatalogError{sentinel: s, params: kvParams(kv), stack: callers(0)}
+}
+
+// Wrap creates an error of the sentinel caused by err, with parameters given as key/value pairs
+func (s *Sentinel) Wrap(err error, kv ...interface{}) *CatalogError {
+	return &CatalogError{sentinel: s, params: kvParams(kv), cause: err, stack: stackFor(err, 0)}
+}
+
+func (s *Sentinel) translation(locale string) string {
+	s.catalog.mu.RLock()
+	defer s.catalog.mu.RUnlock()
+	return s.translations[locale]
+}
+
+func (s *Sentinel) translationsCopy() map[string]string {
+	s.catalog.mu.RLock()
+	defer s.catalog.mu.RUnlock()
+
+	if len(s.translations) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(s.translations))
+	for l, m := range s.translations {
+		out[l] = m
+	}
+	return out
+}
+
+// template finds the best template for the locale, falling back from "zh-CN" to "zh" then the default
+func (s *Sentinel) template(locale string) string {
+	for locale != "" {
+		if t := s.translation(locale); t != "" {
+			return t
+		}
+		i := strings.LastIndexAny(locale, "-_")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return s.message
+}
+
+// CatalogError is an error of a Sentinel with its parameters
+type CatalogError struct {
+	sentinel *Sentinel
+	params   map[string]interface{}
+	cause    error
+	stack    Stack
+}
+
+// Error returns the code and the default message, followed by the cause
+func (e *CatalogError) Error() string {
+	msg := e.sentinel.code + ": " + render(e.sentinel.message, e.params)
+	if v, ok := e.params[badKey]; ok {
+		msg += fmt.Sprintf(" (%s=%v)", badKey, v)
+	}
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+	return msg
+}
+
+// Localize returns the message for the locale, without the code and the cause
+func (e *CatalogError) Localize(locale string) string {
+	return render(e.sentinel.template(locale), e.params)
+}
+
+// Code returns the stable code of the sentinel
+func (e *CatalogError) Code() string {
+	return e.sentinel.code
+}
+
+// ErrorCode returns the Code of the sentinel, or the one of the cause if the sentinel has none
+func (e *CatalogError) ErrorCode() Code {
+	if c := e.sentinel.ErrorCode(); c != "" {
+		return c
+	}
+	if e.cause != nil {
+		return CodeOf(e.cause)
+	}
+	return ""
+}
+
+// Params returns the parameters
+func (e *CatalogError) Params() map[string]interface{} {
+	return e.params
+}
+
+// Is matches the sentinel of the error
+func (e *CatalogError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+func (e *CatalogError) Unwrap() error {
+	return e.cause
+}
+
+// Stack returns the stack where the error is created, nil if its cause has one
+func (e *CatalogError) Stack() Stack {
+	return e.stack
+}
+
+// Format renders the whole chain with the stack for %+v
+func (e *CatalogError) Format(s fmt.State, verb rune) {
+	format(e, s, verb)
+}
+
+// Localize returns the localized message of the first catalog error in the chain of err,
+// or the message of err if there is none
+func Localize(err error, locale string) string {
+	var ce *CatalogError
+	if errors.As(err, &ce) {
+		return ce.Localize(locale)
+	}
+
+	var s *Sentinel
+	if errors.As(err, &s) {
+		return render(s.template(locale), nil)
+	}
+
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+func placeholders(message string) []string {
+	var out []string
+	for _, m := range placeholder.FindAllStringSubmatch(message, -1) {
+		if !contains(out, m[1]) {
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
+
+// render fills parameters in the template, unknown ones are left as is
+func render(template string, params map[string]interface{}) string {
+	return placeholder.ReplaceAllStringFunc(template, func(m string) string {
+		if v, ok := params[m[1:len(m)-1]]; ok {
+			return fmt.Sprint(v)
+		}
+		return m
+	})
+}
+
+// badKey holds the value left without a key in an odd number of key/value pairs
+const badKey = "!BADKEY"
+
+func kvParams(kv []interface{}) map[string]interface{} {
+	if len(kv) == 0 {
+		return nil
+	}
+
+	params := make(map[string]interface{}, (len(kv)+1)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		params[fmt.Sprint(kv[i])] = kv[i+1]
+	}
+	if len(kv)%2 == 1 {
+		params[badKey] = kv[len(kv)-1]
+	}
+	return params
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
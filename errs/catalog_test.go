@@ -0,0 +1,90 @@
+package errs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	c := NewCatalog()
+	errRead := c.Define("CFG-001", "cannot read config file {path}").
+		Translate("zh", "无法读取配置文件 {path}")
+	errKey := c.Define("CFG-002", "missing key {key} in {path}")
+
+	assert.Panics(t, func() { c.Define("CFG-001", "again") })
+	assert.Panics(t, func() { errKey.Translate("zh", "缺少 {name}") })
+
+	err := fmt.Errorf("start: %w", errRead.Wrap(io.EOF, "path", "app.yaml"))
+	assert.Equal(t, "start: CFG-001: cannot read config file app.yaml: EOF", err.Error())
+	assert.True(t, errors.Is(err, errRead))
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.False(t, errors.Is(err, errKey))
+	assert.NotNil(t, StackOf(err))
+
+	assert.Equal(t, "无法读取配置文件 app.yaml", Localize(err, "zh-CN"))
+	assert.Equal(t, "cannot read config file app.yaml", Localize(err, "fr"))
+	assert.Equal(t, "missing key name in {path}", Localize(errKey.New("key", "name"), "zh"))
+	assert.Equal(t, "无法读取配置文件 {path}", Localize(errRead, "zh"))
+	assert.Equal(t, "EOF", Localize(io.EOF, "zh"))
+
+	s, ok := c.Lookup("CFG-002")
+	require.True(t, ok)
+	assert.Equal(t, errKey, s)
+	assert.Equal(t, []string{"zh"}, c.Locales())
+}
+
+func TestCatalogExport(t *testing.T) {
+	c := NewCatalog()
+	c.Define("NET-001", "dial {addr} | timeout")
+	c.Define("CFG-001", "cannot read config file {path}").Translate("zh", "无法读取配置文件 {path}")
+
+	var md bytes.Buffer
+	require.NoError(t, c.WriteMarkdown(&md))
+	assert.Equal(t, "| Code | Message | zh |\n"+
+		"| --- | --- | --- |\n"+
+		"| CFG-001 | cannot read config file {path} | 无法读取配置文件 {path} |\n"+
+		"| NET-001 | dial {addr} \\| timeout |  |\n", md.String())
+
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteJSON(&buf))
+	var entries []catalogEntry
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entries))
+	assert.Equal(t, []catalogEntry{
+		{Code: "CFG-001", Message: "cannot read config file {path}", Params: []string{"path"}, Translations: map[string]string{"zh": "无法读取配置文件 {path}"}},
+		{Code: "NET-001", Message: "dial {addr} | timeout", Params: []string{"addr"}},
+	}, entries)
+}
+
+func TestCatalogCode(t *testing.T) {
+	c := NewCatalog()
+	errMissing := c.Define("CFG-003", "config {path} not found").WithCode(NotFound)
+	errBroken := c.Define("CFG-004", "broken config")
+
+	err := fmt.Errorf("load: %w", errMissing.New("path", "app.yaml"))
+	assert.Equal(t, NotFound, CodeOf(err))
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(err))
+	assert.Equal(t, NotFound, CodeOf(errMissing))
+
+	assert.Equal(t, Unknown, CodeOf(errBroken.New()))
+	assert.Equal(t, DeadlineExceeded, CodeOf(errBroken.Wrap(context.DeadlineExceeded)))
+	assert.Equal(t, PermissionDenied, CodeOf(errBroken.Wrap(PermissionDenied.New("no access"))))
+	assert.Equal(t, InvalidArgument, CodeOf(InvalidArgument.Wrap(errMissing.New(), "")))
+}
+
+func TestCatalogOddParams(t *testing.T) {
+	c := NewCatalog()
+	errKey := c.Define("CFG-005", "missing key {key}")
+
+	err := errKey.New("key", "name", "path")
+	assert.Equal(t, "CFG-005: missing key name (!BADKEY=path)", err.Error())
+	assert.Equal(t, "path", err.Params()["!BADKEY"])
+}
@@ -0,0 +1,133 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Code is a machine readable category of errors, following the canonical codes of gRPC,
+// package grpcerrs maps them to gRPC codes
+type Code string
+
+const (
+	Canceled           Code = "canceled"
+	Unknown            Code = "unknown"
+	InvalidArgument    Code = "invalid_argument"
+	DeadlineExceeded   Code = "deadline_exceeded"
+	NotFound           Code = "not_found"
+	AlreadyExists      Code = "already_exists"
+	PermissionDenied   Code = "permission_denied"
+	ResourceExhausted  Code = "resource_exhausted"
+	FailedPrecondition Code = "failed_precondition"
+	Aborted            Code = "aborted"
+	OutOfRange         Code = "out_of_range"
+	Unimplemented      Code = "unimplemented"
+	Internal           Code = "internal"
+	Unavailable        Code = "unavailable"
+	DataLoss           Code = "data_loss"
+	Unauthenticated    Code = "unauthenticated"
+)
+
+var httpStatuses = map[Code]int{
+	Canceled:           499,
+	Unknown:            http.StatusInternalServerError,
+	InvalidArgument:    http.StatusBadRequest,
+	DeadlineExceeded:   http.StatusGatewayTimeout,
+	NotFound:           http.StatusNotFound,
+	AlreadyExists:      http.StatusConflict,
+	PermissionDenied:   http.StatusForbidden,
+	ResourceExhausted:  http.StatusTooManyRequests,
+	FailedPrecondition: http.StatusBadRequest,
+	Aborted:            http.StatusConflict,
+	OutOfRange:         http.StatusBadRequest,
+	Unimplemented:      http.StatusNotImplemented,
+	Internal:           http.StatusInternalServerError,
+	Unavailable:        http.StatusServiceUnavailable,
+	DataLoss:           http.StatusInternalServerError,
+	Unauthenticated:    http.StatusUnauthorized,
+}
+
+// HTTPStatus maps the code to a http status code, unknown codes are internal server errors
+func (c Code) HTTPStatus() int {
+	if s, ok := httpStatuses[c]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// New creates an Error of the code
+func (c Code) New(format string, args ...interface{}) *Error {
+	return newError(c, nil, format, args)
+}
+
+// Wrap creates an Error of the code caused by err
+func (c Code) Wrap(err error, format string, args ...interface{}) *Error {
+	return newError(c, err, format, args)
+}
+
+// coded errors carry a Code, like Error and CatalogError
+type coded interface {
+	ErrorCode() Code
+}
+
+// CodeOf returns the code of the first Error or CatalogError in the chain of err.
+// Context errors are mapped to Canceled and DeadlineExceeded, other errors are Unknown, and nil has no code.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+
+	var e coded
+	if errors.As(err, &e) {
+		if c := e.ErrorCode(); c != "" {
+			return c
+		}
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded
+	}
+	return Unknown
+}
+
+// HTTPStatus maps err to a http status code by its code, nil is http.StatusOK
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	return CodeOf(err).HTTPStatus()
+}
+
+// CodeFromHTTPStatus maps a http status code back to a code, it is meant for clients
+func CodeFromHTTPStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return InvalidArgument
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusConflict:
+		return AlreadyExists
+	case http.StatusTooManyRequests:
+		return ResourceExhausted
+	case 499:
+		return Canceled
+	case http.StatusNotImplemented:
+		return Unimplemented
+	case http.StatusServiceUnavailable:
+		return Unavailable
+	case http.StatusGatewayTimeout:
+		return DeadlineExceeded
+	}
+	if status >= 500 {
+		return Internal
+	}
+	return Unknown
+}
@@ -0,0 +1,134 @@
+package errs
+
+import (
+	"sort"
+	"sync"
+)
+
+// Collector collects errors from many goroutines safely, unlike errgroup which keeps only the first one
+type Collector struct {
+	mu      sync.Mutex
+	limit   int
+	dedup   bool
+	errs    Errors
+	seen    map[string]bool
+	groups  map[string]Errors
+	dropped int
+}
+
+// CollectorOption configures a Collector
+type CollectorOption func(*Collector)
+
+// KeepAtMost keeps the first n errors and drops the others, n <= 0 means no limit.
+// The limit applies to all groups together.
+func KeepAtMost(n int) CollectorOption {
+	return func(c *Collector) {
+		c.limit = n
+	}
+}
+
+// Dedup drops errors with the same message as one collected before
+func Dedup() CollectorOption {
+	return func(c *Collector) {
+		c.dedup = true
+	}
+}
+
+// NewCollector creates a Collector
+func NewCollector(opts ...CollectorOption) *Collector {
+	c := &Collector{
+		seen:   make(map[string]bool),
+		groups: make(map[string]Errors),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Add collects an error, nil is ignored, multi-errors are flattened
+func (c *Collector) Add(err error) {
+	c.AddKey("", err)
+}
+
+// AddKey collects an error in the group of key, errors added by Add are in the group of ""
+func (c *Collector) AddKey(key string, err error) {
+	flat := Append(err)
+	if len(flat) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range flat {
+		if c.limit > 0 && len(c.errs) >= c.limit {
+			c.dropped++
+			continue
+		}
+		if c.dedup {
+			msg := key + "\x00" + e.Error()
+			if c.seen[msg] {
+				c.dropped++
+				continue
+			}
+			c.seen[msg] = true
+		}
+		c.groups[key] = append(c.groups[key], e)
+		c.errs = append(c.errs, e)
+	}
+}
+
+// Errors returns all collected errors in the order they are added, nil if there is none
+func (c *Collector) Errors() Errors {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return append(Errors(nil), c.errs...)
+}
+
+// Err returns the collected errors as an error, or nil if there is none
+func (c *Collector) Err() error {
+	return c.Errors().ErrorOrNil()
+}
+
+// Group returns errors collected for key
+func (c *Collector) Group(key string) Errors {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.groups[key]) == 0 {
+		return nil
+	}
+	return append(Errors(nil), c.groups[key]...)
+}
+
+// Keys returns keys of all groups with errors in order
+func (c *Collector) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.groups))
+	for k := range c.groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns the number of collected errors
+func (c *Collector) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.errs)
+}
+
+// Dropped returns the number of errors dropped by the limit or deduplication
+func (c *Collector) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
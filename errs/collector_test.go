@@ -0,0 +1,62 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	assert.Nil(t, c.Err())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				c.Add(fmt.Errorf("worker %d failed", i))
+			} else {
+				c.Add(nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 50, c.Len())
+	assert.Len(t, c.Errors(), 50)
+	assert.Error(t, c.Err())
+	assert.Zero(t, c.Dropped())
+}
+
+func TestCollectorOptions(t *testing.T) {
+	c := NewCollector(Dedup(), KeepAtMost(4))
+
+	timeout := errors.New("timeout")
+	c.AddKey("db", timeout)
+	c.AddKey("db", errors.New("timeout"))
+	c.AddKey("db", errors.New("connection refused"))
+	c.AddKey("db", errors.New("too many connections"))
+	c.AddKey("cache", Errors{errors.New("timeout"), errors.New("evicted")})
+	c.Add(errors.New("unknown"))
+
+	assert.Equal(t, []string{"cache", "db"}, c.Keys())
+	assert.Equal(t, Errors{timeout, errors.New("connection refused"), errors.New("too many connections")}, c.Group("db"))
+	assert.Equal(t, Errors{errors.New("timeout")}, c.Group("cache"))
+	assert.Nil(t, c.Group("queue"))
+	assert.Equal(t, 4, c.Len())
+	// one duplicate, two over the limit shared by all groups
+	assert.Equal(t, 3, c.Dropped())
+
+	c = NewCollector(KeepAtMost(10))
+	for i := 0; i < 100; i++ {
+		c.AddKey(fmt.Sprint(i), errors.New("failed"))
+	}
+	assert.Equal(t, 10, c.Len())
+	assert.Len(t, c.Keys(), 10)
+	assert.Equal(t, 90, c.Dropped())
+}
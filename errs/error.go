@@ -0,0 +1,105 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error is a structured error with a machine readable code, a human readable message,
+// details as key/value pairs, a cause and the stack where it is created,
+// unless the cause has a stack already.
+type Error struct {
+	Code    Code
+	Message string
+	Details map[string]interface{}
+	Cause   error
+
+	stack Stack
+}
+
+func newError(code Code, cause error, format string, args []interface{}) *Error {
+	msg := format
+	if len(args) > 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+	return &Error{
+		Code:    code,
+		Message: msg,
+		Cause:   cause,
+		stack:   stackFor(cause, 1),
+	}
+}
+
+// With adds a detail to the error and returns it, so it could be chained after creation
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Error returns the message followed by the cause
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = string(e.Code)
+	}
+	if e.Cause != nil {
+		return msg + ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+// ErrorCode returns e.Code, so Error and CatalogError are found alike by CodeOf
+func (e *Error) ErrorCode() Code {
+	return e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Stack returns the stack where the error is created, nil if its cause has one
+func (e *Error) Stack() Stack {
+	return e.stack
+}
+
+// errorBody is the json form of Error, causes and stacks are left out since they are internal
+type errorBody struct {
+	Code    Code                   `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// MarshalJSON encodes the error as an API error body, the cause and the stack are not exposed
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(errorBody{Code: e.Code, Message: e.Message, Details: e.Details})
+}
+
+// UnmarshalJSON decodes an API error body, it is meant for clients
+func (e *Error) UnmarshalJSON(b []byte) error {
+	var body errorBody
+	if err := json.Unmarshal(b, &body); err != nil {
+		return err
+	}
+	*e = Error{Code: body.Code, Message: body.Message, Details: body.Details}
+	return nil
+}
+
+// WriteHTTP writes err as a json error body with the mapped status code.
+// Errors without an Error in their chain are written as internal errors without their messages,
+// so nothing internal is leaked.
+func WriteHTTP(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		code := CodeOf(err)
+		e = &Error{Code: code, Message: http.StatusText(code.HTTPStatus())}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(e.Code.HTTPStatus())
+	json.NewEncoder(w).Encode(e)
+}
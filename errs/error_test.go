@@ -0,0 +1,72 @@
+package errs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestError(t *testing.T) {
+	e := NotFound.Wrap(io.EOF, "user %s not found", "ava").With("user", "ava")
+	assert.Equal(t, "user ava not found: EOF", e.Error())
+	assert.True(t, errors.Is(e, io.EOF))
+	assert.Equal(t, map[string]interface{}{"user": "ava"}, e.Details)
+	assert.Contains(t, e.Stack().String(), "errs.TestError")
+
+	wrapped := fmt.Errorf("handle request: %w", e)
+	assert.Equal(t, NotFound, CodeOf(wrapped))
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(wrapped))
+
+	assert.Equal(t, "internal", Internal.New("").Error())
+}
+
+func TestCodeOf(t *testing.T) {
+	assert.Equal(t, Code(""), CodeOf(nil))
+	assert.Equal(t, Unknown, CodeOf(io.EOF))
+	assert.Equal(t, Canceled, CodeOf(fmt.Errorf("wrapped: %w", context.Canceled)))
+	assert.Equal(t, DeadlineExceeded, CodeOf(context.DeadlineExceeded))
+
+	assert.Equal(t, http.StatusOK, HTTPStatus(nil))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(io.EOF))
+	assert.Equal(t, http.StatusInternalServerError, Code("bogus").HTTPStatus())
+
+	assert.Equal(t, NotFound, CodeFromHTTPStatus(http.StatusNotFound))
+	assert.Equal(t, Internal, CodeFromHTTPStatus(http.StatusBadGateway))
+	assert.Equal(t, Unknown, CodeFromHTTPStatus(http.StatusTeapot))
+}
+
+func TestErrorJSON(t *testing.T) {
+	e := InvalidArgument.Wrap(io.EOF, "bad request body").With("field", "name")
+	b, err := json.Marshal(e)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"code":"invalid_argument","message":"bad request body","details":{"field":"name"}}`, string(b))
+
+	var got Error
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, InvalidArgument, got.Code)
+	assert.Equal(t, "bad request body", got.Message)
+	assert.Equal(t, map[string]interface{}{"field": "name"}, got.Details)
+}
+
+func TestWriteHTTP(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteHTTP(rec, fmt.Errorf("wrapped: %w", PermissionDenied.New("no access to %s", "bucket")))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":"permission_denied","message":"no access to bucket"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	WriteHTTP(rec, errors.New("database password is hunter2"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.False(t, strings.Contains(rec.Body.String(), "hunter2"))
+	assert.JSONEq(t, `{"code":"unknown","message":"Internal Server Error"}`, rec.Body.String())
+}
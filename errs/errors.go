@@ -1,9 +1,97 @@
 package errs
 
-import "fmt"
+import (
+	"strconv"
+	"strings"
+)
 
+// Errors is a list of errors, which is an error itself.
+// errors.Is and errors.As look into every error of the list,
+// and zap logs them as causes of the error.
 type Errors []error
 
+// Append appends errors to err, flattening nested multi-errors and dropping nils.
+// Multi-errors are Errors, errors from errors.Join, and errors of go.uber.org/multierr.
+// The result is empty but not nil as an error if all errors are nil, use ErrorOrNil before returning it.
+func Append(err error, errs ...error) Errors {
+	var out Errors
+	out = flatten(out, err)
+	for _, e := range errs {
+		out = flatten(out, e)
+	}
+	return out
+}
+
+func flatten(out Errors, err error) Errors {
+	switch e := err.(type) {
+	case nil:
+		return out
+	case Errors:
+		for _, inner := range e {
+			out = flatten(out, inner)
+		}
+		return out
+	case interface{ Errors() []error }:
+		// go.uber.org/multierr
+		for _, inner := range e.Errors() {
+			out = flatten(out, inner)
+		}
+		return out
+	case interface{ Unwrap() []error }:
+		// errors.Join, whose message is nothing but messages of its errors,
+		// other errors wrapping many, like fmt.Errorf with several %w, have their own message to keep
+		inners := e.Unwrap()
+		msgs := make([]string, 0, len(inners))
+		for _, inner := range inners {
+			if inner != nil {
+				msgs = append(msgs, inner.Error())
+			}
+		}
+		if err.Error() != strings.Join(msgs, "\n") {
+			return append(out, err)
+		}
+		for _, inner := range inners {
+			out = flatten(out, inner)
+		}
+		return out
+	default:
+		return append(out, err)
+	}
+}
+
+// ErrorOrNil returns nil if there is no error, or the errors otherwise
+func (e Errors) ErrorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error returns the message of a single error as is, or lists multiple errors a line each
 func (e Errors) Error() string {
-	return fmt.Sprint("errors: ", e)
+	switch len(e) {
+	case 0:
+		return "no error"
+	case 1:
+		return e[0].Error()
+	}
+
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(len(e)))
+	b.WriteString(" errors occurred:")
+	for _, err := range e {
+		b.WriteString("\n\t* ")
+		b.WriteString(strings.ReplaceAll(err.Error(), "\n", "\n\t  "))
+	}
+	return b.String()
+}
+
+// Unwrap lets errors.Is and errors.As look into every error
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Errors returns the list as is, following the errorGroup convention of go.uber.org/multierr which zap uses
+func (e Errors) Errors() []error {
+	return e
 }
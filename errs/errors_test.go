@@ -0,0 +1,64 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+)
+
+func TestAppend(t *testing.T) {
+	e1 := errors.New("e1")
+	e2 := errors.New("e2")
+	e3 := errors.New("e3")
+	e4 := errors.New("e4")
+	e5 := errors.New("e5")
+
+	es := Append(nil, e1, nil, Errors{e2, Errors{e3}}, errors.Join(e4, nil), multierr.Combine(e5, nil))
+	assert.Equal(t, Errors{e1, e2, e3, e4, e5}, es)
+
+	es = Append(Errors{e1}, multierr.Combine(e2, e3))
+	assert.Equal(t, Errors{e1, e2, e3}, es)
+
+	// has a message of its own
+	wrapped := fmt.Errorf("both %w and %w", e1, e2)
+	assert.Equal(t, Errors{wrapped}, Append(nil, wrapped))
+
+	assert.Nil(t, Append(nil, nil, nil).ErrorOrNil())
+	assert.Equal(t, Errors{e1}, Append(e1).ErrorOrNil())
+}
+
+func TestIsAs(t *testing.T) {
+	pathErr := &os.PathError{Op: "open", Path: "/nowhere", Err: os.ErrNotExist}
+	err := Append(errors.New("e1"), fmt.Errorf("wrapped: %w", pathErr)).ErrorOrNil()
+
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.False(t, errors.Is(err, io.EOF))
+
+	var target *os.PathError
+	assert.True(t, errors.As(err, &target))
+	assert.Equal(t, pathErr, target)
+
+	joined := errors.Join(errors.New("other"), err)
+	assert.True(t, errors.Is(joined, os.ErrNotExist))
+}
+
+func TestMultierr(t *testing.T) {
+	e1 := errors.New("e1")
+	e2 := errors.New("e2")
+	e3 := errors.New("e3")
+
+	es := Errors{e1, e2}
+	// multierr keeps Errors as a single error, flattening them back works
+	assert.Equal(t, Errors{e1, e2, e3}, Append(multierr.Append(es, e3)))
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "e1", Errors{errors.New("e1")}.Error())
+	assert.Equal(t, "3 errors occurred:\n\t* e1\n\t* multi\n\t  line\n\t* e3",
+		Errors{errors.New("e1"), errors.New("multi\nline"), errors.New("e3")}.Error())
+}
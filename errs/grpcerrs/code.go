@@ -0,0 +1,53 @@
+// Package grpcerrs maps the codes of package errs to gRPC codes and back,
+// it is kept apart so that errs does not depend on gRPC.
+package grpcerrs
+
+import (
+	"github.com/supremind/pkg/errs"
+	"google.golang.org/grpc/codes"
+)
+
+var mappings = map[errs.Code]codes.Code{
+	errs.Canceled:           codes.Canceled,
+	errs.Unknown:            codes.Unknown,
+	errs.InvalidArgument:    codes.InvalidArgument,
+	errs.DeadlineExceeded:   codes.DeadlineExceeded,
+	errs.NotFound:           codes.NotFound,
+	errs.AlreadyExists:      codes.AlreadyExists,
+	errs.PermissionDenied:   codes.PermissionDenied,
+	errs.ResourceExhausted:  codes.ResourceExhausted,
+	errs.FailedPrecondition: codes.FailedPrecondition,
+	errs.Aborted:            codes.Aborted,
+	errs.OutOfRange:         codes.OutOfRange,
+	errs.Unimplemented:      codes.Unimplemented,
+	errs.Internal:           codes.Internal,
+	errs.Unavailable:        codes.Unavailable,
+	errs.DataLoss:           codes.DataLoss,
+	errs.Unauthenticated:    codes.Unauthenticated,
+}
+
+// FromCode maps the code to a gRPC code, unknown codes are codes.Unknown
+func FromCode(c errs.Code) codes.Code {
+	if m, ok := mappings[c]; ok {
+		return m
+	}
+	return codes.Unknown
+}
+
+// Code maps err to a gRPC code by its errs.Code, nil is codes.OK
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	return FromCode(errs.CodeOf(err))
+}
+
+// ToCode maps a gRPC code back to a code, it is meant for clients
+func ToCode(c codes.Code) errs.Code {
+	for code, m := range mappings {
+		if m == c {
+			return code
+		}
+	}
+	return errs.Unknown
+}
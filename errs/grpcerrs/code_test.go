@@ -0,0 +1,28 @@
+package grpcerrs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/supremind/pkg/errs"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCode(t *testing.T) {
+	assert.Equal(t, codes.NotFound, Code(fmt.Errorf("handle request: %w", errs.NotFound.New("user not found"))))
+	assert.Equal(t, codes.OK, Code(nil))
+	assert.Equal(t, codes.Unknown, Code(io.EOF))
+	assert.Equal(t, codes.Unknown, FromCode("bogus"))
+
+	c := errs.NewCatalog()
+	assert.Equal(t, codes.NotFound, Code(c.Define("CFG-003", "config {path} not found").WithCode(errs.NotFound).New("path", "app.yaml")))
+	assert.Equal(t, codes.Unknown, Code(errors.New("plain")))
+
+	for code := range mappings {
+		assert.Equal(t, code, ToCode(FromCode(code)))
+	}
+	assert.Equal(t, errs.Unknown, ToCode(codes.OK))
+}
@@ -0,0 +1,90 @@
+package errs
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// PanicError is a recovered panic, keeping the panic value and the stack where it panicked
+type PanicError struct {
+	Value interface{}
+	stack Stack
+}
+
+// NewPanicError converts a recovered value, it should be called in the deferred function
+// so the stack could be captured where the panic happened
+func NewPanicError(v interface{}) *PanicError {
+	if p, ok := v.(*PanicError); ok {
+		return p
+	}
+	return &PanicError{Value: v, stack: panicStack()}
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Unwrap returns the panic value if it is an error
+func (p *PanicError) Unwrap() error {
+	if e, ok := p.Value.(error); ok {
+		return e
+	}
+	return nil
+}
+
+// Stack returns the stack where it panicked
+func (p *PanicError) Stack() Stack {
+	return p.stack
+}
+
+// Format renders the whole chain with the stack for %+v
+func (p *PanicError) Format(s fmt.State, verb rune) {
+	format(p, s, verb)
+}
+
+// Recover converts a panic into a PanicError and sets it to err, it must be deferred directly:
+//
+//	defer errs.Recover(&err)
+func Recover(err *error) {
+	if r := recover(); r != nil {
+		*err = NewPanicError(r)
+	}
+}
+
+// SafeGo runs f in a goroutine, a panic in f is recovered and reported to handle as a PanicError,
+// or dropped if handle is nil
+func SafeGo(handle func(error), f func()) {
+	go func() {
+		var err error
+		defer func() {
+			if err != nil && handle != nil {
+				handle(err)
+			}
+		}()
+		defer Recover(&err)
+
+		f()
+	}()
+}
+
+// panicStack captures the stack starting from the function which panicked,
+// dropping the frames of recovering and the runtime
+func panicStack() Stack {
+	s := callers(1)
+	for i, pc := range s {
+		if fn := runtime.FuncForPC(pc - 1); fn == nil || fn.Name() != "runtime.gopanic" {
+			continue
+		}
+
+		// runtime errors panic through frames like runtime.panicmem and runtime.sigpanic
+		j := i + 1
+		for ; j < len(s); j++ {
+			if fn := runtime.FuncForPC(s[j] - 1); fn == nil || !strings.HasPrefix(fn.Name(), "runtime.") {
+				break
+			}
+		}
+		return s[j:]
+	}
+	return s
+}
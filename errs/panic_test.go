@@ -0,0 +1,74 @@
+package errs
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func explode(v interface{}) {
+	panic(v)
+}
+
+func dereference(p *int) int {
+	return *p
+}
+
+func call(f func()) (err error) {
+	defer Recover(&err)
+	f()
+	return nil
+}
+
+func TestRecover(t *testing.T) {
+	err := call(func() { explode(io.EOF) })
+	var p *PanicError
+	require.True(t, errors.As(err, &p))
+	assert.Equal(t, io.EOF, p.Value)
+	assert.Equal(t, "panic: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, "github.com/supremind/pkg/errs.explode", p.Stack().Frames()[0].Function)
+
+	err = call(func() { dereference(nil) })
+	require.True(t, errors.As(err, &p))
+	_, ok := p.Value.(error)
+	assert.True(t, ok)
+	assert.Equal(t, "github.com/supremind/pkg/errs.dereference", p.Stack().Frames()[0].Function)
+
+	err = call(func() { explode(42) })
+	require.True(t, errors.As(err, &p))
+	assert.Equal(t, 42, p.Value)
+	assert.Nil(t, errors.Unwrap(err))
+
+	// re-panicked errors keep the original stack
+	again := call(func() { panic(err) })
+	assert.Same(t, err, again)
+
+	assert.NoError(t, call(func() {}))
+}
+
+func TestSafeGo(t *testing.T) {
+	reported := make(chan error, 1)
+	SafeGo(func(err error) { reported <- err }, func() { explode("boom") })
+
+	err := <-reported
+	var p *PanicError
+	require.True(t, errors.As(err, &p))
+	assert.Equal(t, "boom", p.Value)
+	assert.Equal(t, "github.com/supremind/pkg/errs.explode", p.Stack().Frames()[0].Function)
+
+	done := make(chan struct{})
+	SafeGo(func(err error) { reported <- err }, func() { close(done) })
+	<-done
+	assert.Empty(t, reported)
+
+	recovered := make(chan struct{})
+	SafeGo(nil, func() {
+		defer close(recovered)
+		explode("boom")
+	})
+	<-recovered
+}
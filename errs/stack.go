@@ -0,0 +1,48 @@
+package errs
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Stack is a captured call stack
+type Stack []uintptr
+
+const maxStackDepth = 32
+
+// callers captures the stack of the caller of its caller, skip is the number of extra frames to skip
+func callers(skip int) Stack {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+3, pcs)
+	return Stack(pcs[:n])
+}
+
+// Frames resolves the program counters to frames
+func (s Stack) Frames() []runtime.Frame {
+	if len(s) == 0 {
+		return nil
+	}
+
+	var out []runtime.Frame
+	frames := runtime.CallersFrames(s)
+	for {
+		f, more := frames.Next()
+		out = append(out, f)
+		if !more {
+			return out
+		}
+	}
+}
+
+// String formats the stack like a goroutine trace, a function and its location a frame
+func (s Stack) String() string {
+	var b strings.Builder
+	for i, f := range s.Frames() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%s\n\t%s:%d", f.Function, f.File, f.Line)
+	}
+	return b.String()
+}
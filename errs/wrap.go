@@ -0,0 +1,169 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// wrapped adds a message and maybe a stack to its cause,
+// the stack is captured only if there is none in the chain yet
+type wrapped struct {
+	msg   string
+	cause error
+	stack Stack
+}
+
+// New creates an error with a stack
+func New(msg string) error {
+	return &wrapped{msg: msg, stack: callers(0)}
+}
+
+// Errorf formats an error like fmt.Errorf, which wraps errors with %w,
+// and captures a stack if there is none in the chain
+func Errorf(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	if StackOf(err) != nil {
+		return err
+	}
+	return &wrapped{cause: err, stack: callers(0)}
+}
+
+// Wrap adds a message to err, and captures a stack if there is none in the chain. A nil err is returned as is.
+func Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &wrapped{msg: msg, cause: err, stack: stackFor(err, 0)}
+}
+
+// Wrapf is Wrap with a formatted message
+func Wrapf(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &wrapped{msg: fmt.Sprintf(format, args...), cause: err, stack: stackFor(err, 0)}
+}
+
+// WithStack captures a stack for err if there is none in the chain. A nil err is returned as is.
+func WithStack(err error) error {
+	if err == nil || StackOf(err) != nil {
+		return err
+	}
+	return &wrapped{cause: err, stack: callers(0)}
+}
+
+// stackFor captures the stack of the caller of its caller like callers, unless err already has one
+func stackFor(err error, skip int) Stack {
+	if StackOf(err) != nil {
+		return nil
+	}
+	return callers(skip + 1)
+}
+
+func (w *wrapped) Error() string {
+	switch {
+	case w.cause == nil:
+		return w.msg
+	case w.msg == "":
+		return w.cause.Error()
+	}
+	return w.msg + ": " + w.cause.Error()
+}
+
+func (w *wrapped) Unwrap() error {
+	return w.cause
+}
+
+func (w *wrapped) Stack() Stack {
+	return w.stack
+}
+
+// Format renders the whole chain with the stack for %+v
+func (w *wrapped) Format(s fmt.State, verb rune) {
+	format(w, s, verb)
+}
+
+// Format renders the whole chain with the stack for %+v
+func (e *Error) Format(s fmt.State, verb rune) {
+	format(e, s, verb)
+}
+
+func format(err error, s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		io.WriteString(s, Render(err))
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", err.Error())
+	default:
+		io.WriteString(s, err.Error())
+	}
+}
+
+// StackOf returns the innermost stack in the chain of err, which is where the error started,
+// or nil if there is none. Stacks captured by github.com/pkg/errors are recognized as well.
+func StackOf(err error) Stack {
+	var found Stack
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch e := err.(type) {
+		case interface{ Stack() Stack }:
+			if s := e.Stack(); len(s) > 0 {
+				found = s
+			}
+		case interface{ StackTrace() pkgerrors.StackTrace }:
+			if st := e.StackTrace(); len(st) > 0 {
+				s := make(Stack, len(st))
+				for i, f := range st {
+					s[i] = uintptr(f)
+				}
+				found = s
+			}
+		}
+	}
+	return found
+}
+
+// Chain returns messages of every error in the chain of err from the outermost,
+// each without the message of its cause
+func Chain(err error) []string {
+	var chain []string
+	for ; err != nil; err = errors.Unwrap(err) {
+		msg := err.Error()
+		if cause := errors.Unwrap(err); cause != nil {
+			if msg == cause.Error() {
+				// adds nothing but a stack
+				continue
+			}
+			msg = strings.TrimSuffix(msg, ": "+cause.Error())
+		}
+		chain = append(chain, msg)
+	}
+	return chain
+}
+
+// Render formats err with its chain of causes and its stack, one item a line
+func Render(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+
+	var b strings.Builder
+	b.WriteString(err.Error())
+
+	if chain := Chain(err); len(chain) > 1 {
+		b.WriteString("\nchain:")
+		for _, msg := range chain {
+			b.WriteString("\n\t")
+			b.WriteString(strings.ReplaceAll(msg, "\n", "\n\t"))
+		}
+	}
+
+	if stack := StackOf(err); len(stack) > 0 {
+		b.WriteString("\nstack:\n\t")
+		b.WriteString(strings.ReplaceAll(stack.String(), "\n", "\n\t"))
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func readConfig() error {
+	return Wrap(io.EOF, "read config")
+}
+
+func loadConfig() error {
+	return Wrapf(readConfig(), "load config %s", "app.json")
+}
+
+func TestWrap(t *testing.T) {
+	err := fmt.Errorf("start: %w", loadConfig())
+	assert.Equal(t, "start: load config app.json: read config: EOF", err.Error())
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, []string{"start", "load config app.json", "read config", "EOF"}, Chain(err))
+
+	// captured once where the chain started
+	stack := StackOf(err)
+	require.NotEmpty(t, stack)
+	assert.Equal(t, "github.com/supremind/pkg/errs.readConfig", stack.Frames()[0].Function)
+	var w *wrapped
+	require.True(t, errors.As(err, &w))
+	assert.Nil(t, w.Stack())
+
+	assert.Nil(t, Wrap(nil, "nothing"))
+	assert.Nil(t, WithStack(nil))
+	assert.Equal(t, err, WithStack(err))
+}
+
+func TestNewErrorf(t *testing.T) {
+	err := New("boom")
+	assert.Equal(t, "boom", err.Error())
+	assert.Equal(t, "github.com/supremind/pkg/errs.TestNewErrorf", StackOf(err).Frames()[0].Function)
+
+	err = Errorf("wrapped: %w", err)
+	assert.Equal(t, "wrapped: boom", err.Error())
+	assert.Equal(t, []string{"wrapped", "boom"}, Chain(err))
+
+	err = Errorf("plain %d", 1)
+	assert.Equal(t, []string{"plain 1"}, Chain(err))
+	assert.NotNil(t, StackOf(err))
+
+	err = NotFound.Wrap(New("no rows"), "user not found")
+	assert.Nil(t, err.(*Error).Stack())
+	assert.NotNil(t, StackOf(err))
+
+	err = NotFound.New("user not found")
+	assert.Equal(t, "github.com/supremind/pkg/errs.TestNewErrorf", err.(*Error).Stack().Frames()[0].Function)
+}
+
+func TestPkgErrorsStack(t *testing.T) {
+	err := Wrap(pkgerrors.New("from pkg/errors"), "wrapped")
+	stack := StackOf(err)
+	require.NotEmpty(t, stack)
+	assert.Equal(t, "github.com/supremind/pkg/errs.TestPkgErrorsStack", stack.Frames()[0].Function)
+}
+
+func TestRender(t *testing.T) {
+	err := loadConfig()
+	assert.Equal(t, "load config app.json: read config: EOF", fmt.Sprintf("%v", err))
+	assert.Equal(t, `"load config app.json: read config: EOF"`, fmt.Sprintf("%q", err))
+
+	rendered := fmt.Sprintf("%+v", err)
+	assert.True(t, strings.HasPrefix(rendered, "load config app.json: read config: EOF\nchain:\n\tload config app.json\n\tread config\n\tEOF\nstack:\n\tgithub.com/supremind/pkg/errs.readConfig\n\t\t"), rendered)
+	assert.Equal(t, rendered, Render(err))
+}
+
+func TestLoggable(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	zap.New(core).Error("failed", zap.Object("error", Loggable(loadConfig())))
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()["error"].(map[string]interface{})
+	assert.Equal(t, "load config app.json: read config: EOF", fields["message"])
+	assert.Equal(t, []interface{}{"load config app.json", "read config", "EOF"}, fields["chain"])
+	assert.Contains(t, fields["stack"], "errs.readConfig")
+
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	buf, e := enc.EncodeEntry(zapcore.Entry{Message: "failed"}, []zapcore.Field{zap.Object("error", Loggable(io.EOF))})
+	require.NoError(t, e)
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, map[string]interface{}{"message": "EOF"}, line["error"])
+}
@@ -0,0 +1,39 @@
+package errs
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// Loggable wraps err to be logged by zap as an object with its message, chain and stack,
+// like {"message": "...", "chain": ["...", "..."], "stack": "..."}
+func Loggable(err error) zapcore.ObjectMarshaler {
+	return loggable{err}
+}
+
+type loggable struct {
+	err error
+}
+
+func (l loggable) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if l.err == nil {
+		return nil
+	}
+
+	enc.AddString("message", l.err.Error())
+
+	if chain := Chain(l.err); len(chain) > 1 {
+		if e := enc.AddArray("chain", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			for _, msg := range chain {
+				arr.AppendString(msg)
+			}
+			return nil
+		})); e != nil {
+			return e
+		}
+	}
+
+	if stack := StackOf(l.err); len(stack) > 0 {
+		enc.AddString("stack", stack.String())
+	}
+	return nil
+}
@@ -1,16 +1,29 @@
 module github.com/supremind/pkg
 
-go 1.14
+go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/go-logr/logr v0.1.0
 	github.com/go-logr/zapr v0.1.1
 	github.com/imdario/mergo v0.3.8
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.4.0
-	go.uber.org/multierr v1.5.0 // indirect
+	go.uber.org/multierr v1.5.0
 	go.uber.org/zap v1.14.0
-	golang.org/x/crypto v0.1.0
+	golang.org/x/crypto v0.17.0
+	golang.org/x/sync v0.3.0
+	google.golang.org/grpc v1.58.3
+	gopkg.in/yaml.v2 v2.2.2
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.uber.org/atomic v1.6.0 // indirect
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
-	golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4
+	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/tools v0.6.0 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
 )
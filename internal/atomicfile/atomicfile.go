@@ -0,0 +1,59 @@
+// Package atomicfile writes files which are never seen partially written
+package atomicfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Write writes a temporary file of the mode in the same directory, syncs it and renames it to path,
+// then syncs the directory to persist the rename.
+// Without overwrite, the file is linked to path instead, which fails with os.ErrExist if path exists.
+func Write(path string, b []byte, perm os.FileMode, overwrite bool) (e error) {
+	dir := filepath.Dir(path)
+	f, e := ioutil.TempFile(dir, "."+filepath.Base(path)+".*.tmp")
+	if e != nil {
+		return fmt.Errorf("create temporary file: %w", e)
+	}
+	defer func() {
+		if e != nil || !overwrite {
+			os.Remove(f.Name())
+		}
+	}()
+
+	if e := f.Chmod(perm); e != nil {
+		f.Close()
+		return fmt.Errorf("chmod %s: %w", f.Name(), e)
+	}
+	if _, e := f.Write(b); e != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", f.Name(), e)
+	}
+	if e := f.Sync(); e != nil {
+		f.Close()
+		return fmt.Errorf("sync %s: %w", f.Name(), e)
+	}
+	if e := f.Close(); e != nil {
+		return fmt.Errorf("close %s: %w", f.Name(), e)
+	}
+
+	if overwrite {
+		if e := os.Rename(f.Name(), path); e != nil {
+			return fmt.Errorf("rename %s: %w", f.Name(), e)
+		}
+	} else if e := os.Link(f.Name(), path); e != nil {
+		return fmt.Errorf("link %s: %w", f.Name(), e)
+	}
+
+	d, e := os.Open(dir)
+	if e != nil {
+		return fmt.Errorf("open directory %s: %w", dir, e)
+	}
+	defer d.Close()
+	if e := d.Sync(); e != nil {
+		return fmt.Errorf("sync directory %s: %w", dir, e)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package atomicfile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	dir, e := ioutil.TempDir("", "atomicfile")
+	require.NoError(t, e)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+
+	require.NoError(t, Write(path, []byte("first"), 0600, false))
+	e = Write(path, []byte("second"), 0600, false)
+	assert.True(t, errors.Is(e, os.ErrExist), "%v", e)
+
+	// replaces the mode of the existing file too
+	require.NoError(t, os.Chmod(path, 0644))
+	require.NoError(t, Write(path, []byte("second"), 0600, true))
+	b, e := ioutil.ReadFile(path)
+	require.NoError(t, e)
+	assert.Equal(t, "second", string(b))
+	info, e := os.Stat(path)
+	require.NoError(t, e)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, e := ioutil.ReadDir(dir)
+	require.NoError(t, e)
+	assert.Len(t, entries, 1)
+}
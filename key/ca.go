@@ -0,0 +1,236 @@
+package key
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// CertRequest describes a certificate to be signed by a CA
+type CertRequest struct {
+	// Key is the public key to be certified
+	Key ssh.PublicKey
+	// KeyID identifies the certificate in logs of sshd
+	KeyID string
+	// Serial is random if not set
+	Serial uint64
+	// Principals are user names for user certificates, or host names for host certificates.
+	// A certificate without principals is valid for anyone, so at least one is required.
+	Principals []string
+	// ValidAfter is now if not set
+	ValidAfter time.Time
+	// ValidBefore is required, certificates are expected to be short-lived
+	ValidBefore time.Time
+	// CriticalOptions like "force-command" and "source-address"
+	CriticalOptions map[string]string
+	// Extensions like "permit-pty", DefaultUserExtensions for user certificates if nil
+	Extensions map[string]string
+}
+
+// DefaultUserExtensions are what ssh-keygen grants user certificates by default
+var DefaultUserExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// supportedCriticalOptions are what OpenSSH understands
+var supportedCriticalOptions = []string{"force-command", "source-address", "verify-required"}
+
+// CA is an SSH certificate authority, which signs user and host certificates
+// and keeps a revocation list of them
+type CA struct {
+	signer ssh.Signer
+
+	mu  sync.Mutex
+	krl *KRL
+}
+
+// NewCA creates a CA with the private key, see GenerateSSHKey and LoadPrivateKey.
+// Its revocation list starts empty and lives in memory only: callers must persist CA.KRL().Marshal()
+// after revoking, and restore it with SetKRL when the CA is created again.
+func NewCA(priv crypto.Signer) (*CA, error) {
+	signer, e := ssh.NewSignerFromSigner(priv)
+	if e != nil {
+		return nil, e
+	}
+	return &CA{signer: signer, krl: &KRL{CA: signer.PublicKey()}}, nil
+}
+
+// PublicKey returns the key of the CA, which is trusted by TrustedUserCAKeys of sshd for user certificates,
+// and by @cert-authority lines in known_hosts for host certificates
+func (ca *CA) PublicKey() ssh.PublicKey {
+	return ca.signer.PublicKey()
+}
+
+// SignUserCert signs a certificate for users to log in as the principals
+func (ca *CA) SignUserCert(req CertRequest) (*ssh.Certificate, error) {
+	if req.Extensions == nil {
+		req.Extensions = DefaultUserExtensions
+	}
+	return ca.sign(ssh.UserCert, req)
+}
+
+// SignHostCert signs a certificate for hosts serving as the principals
+func (ca *CA) SignHostCert(req CertRequest) (*ssh.Certificate, error) {
+	return ca.sign(ssh.HostCert, req)
+}
+
+func (ca *CA) sign(certType uint32, req CertRequest) (*ssh.Certificate, error) {
+	if req.Key == nil {
+		return nil, errors.New("no key to certify")
+	}
+	if _, ok := req.Key.(*ssh.Certificate); ok {
+		return nil, errors.New("cannot certify a certificate")
+	}
+	if len(req.Principals) == 0 {
+		return nil, errors.New("no principals")
+	}
+	if req.ValidBefore.IsZero() {
+		return nil, errors.New("no expiry")
+	}
+	for opt := range req.CriticalOptions {
+		if !supportedCriticalOption(opt) {
+			return nil, fmt.Errorf("unsupported critical option %q", opt)
+		}
+	}
+	if req.ValidAfter.IsZero() {
+		req.ValidAfter = time.Now()
+	}
+	if !req.ValidBefore.After(req.ValidAfter) {
+		return nil, fmt.Errorf("invalid validity window: %s - %s", req.ValidAfter, req.ValidBefore)
+	}
+
+	serial := req.Serial
+	for serial == 0 {
+		var b [8]byte
+		if _, e := rand.Read(b[:]); e != nil {
+			return nil, e
+		}
+		serial = binary.BigEndian.Uint64(b[:])
+	}
+
+	cert := &ssh.Certificate{
+		Key:             req.Key,
+		Serial:          serial,
+		CertType:        certType,
+		KeyId:           req.KeyID,
+		ValidPrincipals: append([]string{}, req.Principals...),
+		ValidAfter:      uint64(req.ValidAfter.Unix()),
+		ValidBefore:     uint64(req.ValidBefore.Unix()),
+		Permissions: ssh.Permissions{
+			CriticalOptions: copyStrings(req.CriticalOptions),
+			Extensions:      copyStrings(req.Extensions),
+		},
+	}
+	if e := cert.SignCert(rand.Reader, ca.signer); e != nil {
+		return nil, e
+	}
+	return cert, nil
+}
+
+func supportedCriticalOption(opt string) bool {
+	for _, o := range supportedCriticalOptions {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+func copyStrings(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// Revoke adds the certificate to the revocation list by its serial
+func (ca *CA) Revoke(cert *ssh.Certificate) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.krl.RevokeSerial(cert.Serial)
+}
+
+// RevokeKeyID revokes all certificates with the key ID
+func (ca *CA) RevokeKeyID(id string) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.krl.RevokeKeyID(id)
+}
+
+// RevokeKey revokes a public key, and all certificates of it
+func (ca *CA) RevokeKey(pub ssh.PublicKey) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.krl.RevokeKey(pub)
+}
+
+// SetKRL replaces the revocation list, usually with a saved one from ParseKRL.
+// The list must be of the CA, a list without a CA is taken as one of the CA, and nil clears the list.
+func (ca *CA) SetKRL(krl *KRL) error {
+	if krl == nil {
+		krl = &KRL{}
+	} else {
+		krl = krl.clone()
+	}
+	if krl.CA == nil {
+		krl.CA = ca.PublicKey()
+	} else if !bytes.Equal(krl.CA.Marshal(), ca.PublicKey().Marshal()) {
+		return errors.New("ssh: revocation list is of another ca")
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.krl = krl
+	return nil
+}
+
+// KRL returns a copy of the revocation list generated now, with its version increased for every revocation
+func (ca *CA) KRL() *KRL {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	krl := ca.krl.clone()
+	krl.Generated = time.Now()
+	return krl
+}
+
+// VerifyUserCert checks the user certificate is signed by the CA, valid now for the user, and not revoked
+func (ca *CA) VerifyUserCert(cert *ssh.Certificate, user string) error {
+	return ca.verify(ssh.UserCert, cert, user)
+}
+
+// VerifyHostCert checks the host certificate is signed by the CA, valid now for the host, and not revoked
+func (ca *CA) VerifyHostCert(cert *ssh.Certificate, host string) error {
+	return ca.verify(ssh.HostCert, cert, host)
+}
+
+func (ca *CA) verify(certType uint32, cert *ssh.Certificate, principal string) error {
+	if cert.CertType != certType {
+		return fmt.Errorf("ssh: certificate type %d is not %d", cert.CertType, certType)
+	}
+	if cert.SignatureKey == nil || !bytes.Equal(cert.SignatureKey.Marshal(), ca.PublicKey().Marshal()) {
+		return errors.New("ssh: certificate is not signed by the ca")
+	}
+
+	checker := ssh.CertChecker{
+		SupportedCriticalOptions: supportedCriticalOptions,
+		IsRevoked: func(c *ssh.Certificate) bool {
+			ca.mu.Lock()
+			defer ca.mu.Unlock()
+			return ca.krl.IsRevoked(c)
+		},
+	}
+	return checker.CheckCert(principal, cert)
+}
@@ -0,0 +1,188 @@
+package key
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func newCA(t *testing.T) *CA {
+	k, e := GenerateSSHKey()
+	require.NoError(t, e)
+	ca, e := NewCA(k.Private)
+	require.NoError(t, e)
+	return ca
+}
+
+func newPublicKey(t *testing.T) ssh.PublicKey {
+	k, e := GenerateSSHKey()
+	require.NoError(t, e)
+	return k.Public
+}
+
+func TestCA(t *testing.T) {
+	ca := newCA(t)
+	now := time.Now()
+
+	cert, e := ca.SignUserCert(CertRequest{
+		Key:             newPublicKey(t),
+		KeyID:           "alice@example.com",
+		Principals:      []string{"alice", "train"},
+		ValidBefore:     now.Add(time.Hour),
+		CriticalOptions: map[string]string{"force-command": "nvidia-smi"},
+	})
+	require.NoError(t, e)
+	assert.Equal(t, uint32(ssh.UserCert), cert.CertType)
+	assert.NotZero(t, cert.Serial)
+	assert.Equal(t, DefaultUserExtensions, cert.Extensions)
+	assert.Equal(t, "nvidia-smi", cert.CriticalOptions["force-command"])
+
+	assert.NoError(t, ca.VerifyUserCert(cert, "train"))
+	assert.Error(t, ca.VerifyUserCert(cert, "root"))
+	assert.Error(t, ca.VerifyHostCert(cert, "alice"))
+	assert.Error(t, newCA(t).VerifyUserCert(cert, "alice"))
+
+	host, e := ca.SignHostCert(CertRequest{
+		Key:         newPublicKey(t),
+		Principals:  []string{"node-1.example.com"},
+		ValidBefore: now.Add(time.Hour),
+	})
+	require.NoError(t, e)
+	assert.Empty(t, host.Extensions)
+	assert.NoError(t, ca.VerifyHostCert(host, "node-1.example.com"))
+
+	expired, e := ca.SignUserCert(CertRequest{Key: newPublicKey(t), Principals: []string{"alice"}, ValidAfter: now.Add(-2 * time.Hour), ValidBefore: now.Add(-time.Hour)})
+	require.NoError(t, e)
+	assert.EqualError(t, ca.VerifyUserCert(expired, "alice"), "ssh: cert has expired")
+
+	early, e := ca.SignUserCert(CertRequest{Key: newPublicKey(t), Principals: []string{"alice"}, ValidAfter: now.Add(time.Hour), ValidBefore: now.Add(2 * time.Hour)})
+	require.NoError(t, e)
+	assert.EqualError(t, ca.VerifyUserCert(early, "alice"), "ssh: cert is not yet valid")
+
+	_, e = ca.SignUserCert(CertRequest{Key: newPublicKey(t), Principals: []string{"alice"}, ValidBefore: now.Add(time.Hour), CriticalOptions: map[string]string{"no-such-option": ""}})
+	assert.EqualError(t, e, `unsupported critical option "no-such-option"`)
+	unknown := &ssh.Certificate{
+		Key:             newPublicKey(t),
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"alice"},
+		ValidBefore:     uint64(now.Add(time.Hour).Unix()),
+		Permissions:     ssh.Permissions{CriticalOptions: map[string]string{"no-such-option": ""}},
+	}
+	require.NoError(t, unknown.SignCert(rand.Reader, ca.signer))
+	assert.Error(t, ca.VerifyUserCert(unknown, "alice"))
+
+	for _, req := range []CertRequest{
+		{Principals: []string{"alice"}, ValidBefore: now.Add(time.Hour)},
+		{Key: newPublicKey(t), ValidBefore: now.Add(time.Hour)},
+		{Key: newPublicKey(t), Principals: []string{"alice"}},
+		{Key: newPublicKey(t), Principals: []string{"alice"}, ValidAfter: now, ValidBefore: now},
+		{Key: cert, Principals: []string{"alice"}, ValidBefore: now.Add(time.Hour)},
+	} {
+		_, e := ca.SignUserCert(req)
+		assert.Error(t, e)
+	}
+
+	ca.Revoke(cert)
+	assert.EqualError(t, ca.VerifyUserCert(cert, "alice"), "ssh: certificate serial "+strconv.FormatUint(cert.Serial, 10)+" revoked")
+	assert.NoError(t, ca.VerifyHostCert(host, "node-1.example.com"))
+	ca.RevokeKey(host.Key)
+	assert.Error(t, ca.VerifyHostCert(host, "node-1.example.com"))
+
+	krl := ca.KRL()
+	assert.Equal(t, uint64(2), krl.Version)
+	assert.True(t, krl.IsRevoked(cert))
+	assert.True(t, krl.IsRevoked(host.Key))
+	assert.False(t, krl.IsRevoked(expired))
+}
+
+func TestCARestoreKRL(t *testing.T) {
+	k, e := GenerateSSHKey()
+	require.NoError(t, e)
+	ca, e := NewCA(k.Private)
+	require.NoError(t, e)
+
+	cert, e := ca.SignUserCert(CertRequest{Key: newPublicKey(t), KeyID: "alice", Principals: []string{"alice"}, ValidBefore: time.Now().Add(time.Hour)})
+	require.NoError(t, e)
+	ca.Revoke(cert)
+	saved := ca.KRL().Marshal()
+
+	// a restarted CA forgets revocations until the saved list is restored
+	ca, e = NewCA(k.Private)
+	require.NoError(t, e)
+	assert.NoError(t, ca.VerifyUserCert(cert, "alice"))
+
+	krl, e := ParseKRL(saved)
+	require.NoError(t, e)
+	require.NoError(t, ca.SetKRL(krl))
+	assert.Error(t, ca.VerifyUserCert(cert, "alice"))
+	assert.Equal(t, uint64(1), ca.KRL().Version)
+
+	assert.Error(t, newCA(t).SetKRL(krl))
+
+	require.NoError(t, ca.SetKRL(nil))
+	assert.NoError(t, ca.VerifyUserCert(cert, "alice"))
+	assert.Zero(t, ca.KRL().Version)
+}
+
+func TestKRL(t *testing.T) {
+	ca := newCA(t)
+	ca.RevokeKeyID("mallory")
+	ca.RevokeKey(newPublicKey(t))
+	for _, s := range []uint64{42, 7, 42} {
+		ca.Revoke(&ssh.Certificate{Serial: s})
+	}
+
+	krl := ca.KRL()
+	krl.Comment = "training nodes"
+	parsed, e := ParseKRL(krl.Marshal())
+	require.NoError(t, e)
+	assert.Equal(t, krl.Version, parsed.Version)
+	assert.Equal(t, krl.Generated.Unix(), parsed.Generated.Unix())
+	assert.Equal(t, "training nodes", parsed.Comment)
+	assert.Equal(t, ca.PublicKey().Marshal(), parsed.CA.Marshal())
+	assert.Equal(t, []uint64{7, 42}, parsed.Serials)
+	assert.Equal(t, []string{"mallory"}, parsed.KeyIDs)
+	assert.Equal(t, krl.KeyHashes, parsed.KeyHashes)
+
+	_, e = ParseKRL([]byte("SSHKRL\n\x00\x00\x00"))
+	assert.Error(t, e)
+	_, e = ParseKRL(krl.Marshal()[:60])
+	assert.Error(t, e)
+}
+
+// ssh-keygen -k -s ca.pub with serials 1-1000, 2000, 2002, 2005 and id mallory, then -u with a plain key
+const sshKeygenKRL = `U1NIS1JMCgAAAAABAAAAAAAAAAAAAAAAatW0BwAAAAAAAAAAAAAAAAAAAAABAAAAcgAAADMAAAAL
+c3NoLWVkMjU1MTkAAAAgvlJGIYkOjoTdW2YsNHf53vskFnoCpoUwiF8tKtFAX7cAAAAAIQAAABAA
+AAAAAAAAAQAAAAAAAAPoIgAAAA0AAAAAAAAH0AAAAAElIwAAAAsAAAAHbWFsbG9yeQIAAAA3AAAA
+MwAAAAtzc2gtZWQyNTUxOQAAACD7QvD3v8qWlekCm69rQmq9D6pVln57QLIqk+u3haN6Jw==`
+
+func TestParseKRLInterop(t *testing.T) {
+	data, e := base64.StdEncoding.DecodeString(sshKeygenKRL)
+	require.NoError(t, e)
+	krl, e := ParseKRL(data)
+	require.NoError(t, e)
+
+	ca, _, _, _, e := ssh.ParseAuthorizedKey([]byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIL5SRiGJDo6E3VtmLDR3+d77JBZ6AqaFMIhfLSrRQF+3"))
+	require.NoError(t, e)
+	plain, _, _, _, e := ssh.ParseAuthorizedKey([]byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIPtC8Pe/ypaV6QKbr2tCar0PqlWWfntAsiqT67eFo3on"))
+	require.NoError(t, e)
+
+	assert.Equal(t, ca.Marshal(), krl.CA.Marshal())
+	assert.Len(t, krl.Serials, 1003)
+	assert.Equal(t, []uint64{1, 1000, 2000, 2002, 2005}, []uint64{krl.Serials[0], krl.Serials[999], krl.Serials[1000], krl.Serials[1001], krl.Serials[1002]})
+	assert.Equal(t, []string{"mallory"}, krl.KeyIDs)
+	h := sha256.Sum256(plain.Marshal())
+	assert.Equal(t, [][]byte{h[:]}, krl.KeyHashes)
+
+	assert.True(t, krl.IsRevoked(plain))
+	assert.True(t, krl.IsRevoked(&ssh.Certificate{Key: newPublicKey(t), SignatureKey: ca, Serial: 2002}))
+	assert.False(t, krl.IsRevoked(&ssh.Certificate{Key: newPublicKey(t), SignatureKey: ca, Serial: 2001}))
+	assert.True(t, krl.IsRevoked(&ssh.Certificate{Key: newPublicKey(t), SignatureKey: ca, Serial: 2001, KeyId: "mallory"}))
+}
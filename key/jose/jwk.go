@@ -0,0 +1,411 @@
+// Package jose signs and verifies JWS and JWT with keys in JWK, see RFC 7515, 7517 and 7519
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/supremind/pkg/key"
+)
+
+// JWK is a key in JSON, see RFC 7517
+type JWK struct {
+	// Key is a crypto.Signer for private keys, or one of *rsa.PublicKey, *ecdsa.PublicKey and ed25519.PublicKey
+	Key interface{}
+	// KeyID selects the key from a JWKS to verify a token
+	KeyID string
+	// Algorithm like "RS256", "ES256" and "EdDSA" signs tokens, and is the only one accepted to verify them.
+	// Keys without one verify only the default algorithm of their type, like RS256 for RSA keys.
+	Algorithm string
+	// Use is "sig" for signing keys
+	Use string
+}
+
+// GenerateJWK generates a signing key of the type, with its thumbprint as the key ID
+func GenerateJWK(t key.KeyType) (*JWK, error) {
+	priv, e := key.GeneratePrivateKey(t, key.DefaultRSABits)
+	if e != nil {
+		return nil, e
+	}
+	return NewJWK(priv)
+}
+
+// NewJWK creates a signing key with the default algorithm of its type and its thumbprint as the key ID
+func NewJWK(priv crypto.Signer) (*JWK, error) {
+	alg, e := defaultAlgorithm(priv.Public())
+	if e != nil {
+		return nil, e
+	}
+
+	k := &JWK{Key: priv, Algorithm: alg, Use: "sig"}
+	if k.KeyID, e = k.Thumbprint(); e != nil {
+		return nil, e
+	}
+	return k, nil
+}
+
+func defaultAlgorithm(pub crypto.PublicKey) (string, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return "ES256", nil
+		case elliptic.P384():
+			return "ES384", nil
+		case elliptic.P521():
+			return "ES512", nil
+		}
+	case ed25519.PublicKey:
+		return "EdDSA", nil
+	}
+	return "", fmt.Errorf("unsupported key: %T", pub)
+}
+
+// IsPrivate tells if the key could sign
+func (k *JWK) IsPrivate() bool {
+	_, ok := k.Key.(crypto.Signer)
+	return ok
+}
+
+// PublicKey returns the public part of Key
+func (k *JWK) PublicKey() crypto.PublicKey {
+	if s, ok := k.Key.(crypto.Signer); ok {
+		return s.Public()
+	}
+	return k.Key
+}
+
+// Public returns the key without private parts
+func (k *JWK) Public() *JWK {
+	out := *k
+	out.Key = k.PublicKey()
+	return &out
+}
+
+// Thumbprint returns the SHA-256 thumbprint of RFC 7638 in base64url
+func (k *JWK) Thumbprint() (string, error) {
+	var members string
+	switch pub := k.PublicKey().(type) {
+	case *rsa.PublicKey:
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, encodeInt(big.NewInt(int64(pub.E)), 0), encodeInt(pub.N, 0))
+	case *ecdsa.PublicKey:
+		size := curveSize(pub.Curve)
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, pub.Curve.Params().Name, encodeInt(pub.X, size), encodeInt(pub.Y, size))
+	case ed25519.PublicKey:
+		members = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, encode(pub))
+	default:
+		return "", fmt.Errorf("unsupported key: %T", pub)
+	}
+
+	sum := sha256.Sum256([]byte(members))
+	return encode(sum[:]), nil
+}
+
+type jwkJSON struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+	DP  string `json:"dp,omitempty"`
+	DQ  string `json:"dq,omitempty"`
+	QI  string `json:"qi,omitempty"`
+}
+
+// MarshalJSON encodes the key, with private parts if it is private, see Public
+func (k *JWK) MarshalJSON() ([]byte, error) {
+	out := jwkJSON{Kid: k.KeyID, Alg: k.Algorithm, Use: k.Use}
+
+	switch pub := k.PublicKey().(type) {
+	case *rsa.PublicKey:
+		out.Kty, out.N, out.E = "RSA", encodeInt(pub.N, 0), encodeInt(big.NewInt(int64(pub.E)), 0)
+		if priv, ok := k.Key.(*rsa.PrivateKey); ok {
+			if len(priv.Primes) != 2 {
+				return nil, errors.New("multi-prime rsa keys are not supported")
+			}
+			priv.Precompute()
+			out.D = encodeInt(priv.D, 0)
+			out.P, out.Q = encodeInt(priv.Primes[0], 0), encodeInt(priv.Primes[1], 0)
+			out.DP, out.DQ, out.QI = encodeInt(priv.Precomputed.Dp, 0), encodeInt(priv.Precomputed.Dq, 0), encodeInt(priv.Precomputed.Qinv, 0)
+		}
+
+	case *ecdsa.PublicKey:
+		size := curveSize(pub.Curve)
+		out.Kty, out.Crv, out.X, out.Y = "EC", pub.Curve.Params().Name, encodeInt(pub.X, size), encodeInt(pub.Y, size)
+		if priv, ok := k.Key.(*ecdsa.PrivateKey); ok {
+			out.D = encodeInt(priv.D, size)
+		}
+
+	case ed25519.PublicKey:
+		out.Kty, out.Crv, out.X = "OKP", "Ed25519", encode(pub)
+		if priv, ok := k.Key.(ed25519.PrivateKey); ok {
+			out.D = encode(priv.Seed())
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported key: %T", pub)
+	}
+
+	return json.Marshal(out)
+}
+
+// errUnsupportedKey is skipped in a JWKS
+var errUnsupportedKey = errors.New("unsupported key")
+
+func (k *JWK) UnmarshalJSON(b []byte) error {
+	var in jwkJSON
+	if e := json.Unmarshal(b, &in); e != nil {
+		return e
+	}
+
+	var e error
+	switch in.Kty {
+	case "RSA":
+		k.Key, e = parseRSA(&in)
+	case "EC":
+		k.Key, e = parseEC(&in)
+	case "OKP":
+		k.Key, e = parseOKP(&in)
+	default:
+		e = fmt.Errorf("%w: kty %q", errUnsupportedKey, in.Kty)
+	}
+	if e != nil {
+		return e
+	}
+
+	k.KeyID, k.Algorithm, k.Use = in.Kid, in.Alg, in.Use
+	return nil
+}
+
+// minRSABits is the smallest RSA key parsed, smaller than key.MinRSABits to accept keys of other issuers
+const minRSABits = 2048
+
+func parseRSA(in *jwkJSON) (interface{}, error) {
+	n, e := decodeInt(in.N)
+	if e != nil {
+		return nil, fmt.Errorf("jwk n: %w", e)
+	}
+	if n.BitLen() < minRSABits {
+		return nil, fmt.Errorf("jwk n: rsa key size %d is less than %d", n.BitLen(), minRSABits)
+	}
+	exp, e := decodeInt(in.E)
+	if e != nil {
+		return nil, fmt.Errorf("jwk e: %w", e)
+	}
+	if !exp.IsInt64() || exp.Int64() > 1<<31-1 || exp.Int64() < 3 {
+		return nil, errors.New("jwk e: out of range")
+	}
+	pub := rsa.PublicKey{N: n, E: int(exp.Int64())}
+	if in.D == "" {
+		return &pub, nil
+	}
+
+	d, e := decodeInt(in.D)
+	if e != nil {
+		return nil, fmt.Errorf("jwk d: %w", e)
+	}
+	p, e := decodeInt(in.P)
+	if e != nil {
+		return nil, fmt.Errorf("jwk p: %w", e)
+	}
+	q, e := decodeInt(in.Q)
+	if e != nil {
+		return nil, fmt.Errorf("jwk q: %w", e)
+	}
+
+	priv := &rsa.PrivateKey{PublicKey: pub, D: d, Primes: []*big.Int{p, q}}
+	if e := priv.Validate(); e != nil {
+		return nil, fmt.Errorf("jwk: %w", e)
+	}
+	priv.Precompute()
+	return priv, nil
+}
+
+func parseEC(in *jwkJSON) (interface{}, error) {
+	var curve elliptic.Curve
+	switch in.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("%w: crv %q", errUnsupportedKey, in.Crv)
+	}
+
+	size := curveSize(curve)
+	x, e := decodeFixed(in.X, size)
+	if e != nil {
+		return nil, fmt.Errorf("jwk x: %w", e)
+	}
+	y, e := decodeFixed(in.Y, size)
+	if e != nil {
+		return nil, fmt.Errorf("jwk y: %w", e)
+	}
+	pub := ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	ecdhPub, e := pub.ECDH()
+	if e != nil {
+		return nil, fmt.Errorf("jwk: %w", e)
+	}
+	if in.D == "" {
+		return &pub, nil
+	}
+
+	d, e := decodeFixed(in.D, size)
+	if e != nil {
+		return nil, fmt.Errorf("jwk d: %w", e)
+	}
+	priv := &ecdsa.PrivateKey{PublicKey: pub, D: d}
+	ecdhPriv, e := priv.ECDH()
+	if e != nil {
+		return nil, fmt.Errorf("jwk: %w", e)
+	}
+	if !ecdhPriv.PublicKey().Equal(ecdhPub) {
+		return nil, errors.New("jwk: d does not match x and y")
+	}
+	return priv, nil
+}
+
+func parseOKP(in *jwkJSON) (interface{}, error) {
+	if in.Crv != "Ed25519" {
+		return nil, fmt.Errorf("%w: crv %q", errUnsupportedKey, in.Crv)
+	}
+
+	x, e := decode(in.X)
+	if e != nil || len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("jwk x: invalid ed25519 public key")
+	}
+	if in.D == "" {
+		return ed25519.PublicKey(x), nil
+	}
+
+	d, e := decode(in.D)
+	if e != nil || len(d) != ed25519.SeedSize {
+		return nil, errors.New("jwk d: invalid ed25519 private key")
+	}
+	priv := ed25519.NewKeyFromSeed(d)
+	if !priv.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		return nil, errors.New("jwk: d does not match x")
+	}
+	return priv, nil
+}
+
+// JWKS is a set of keys, see RFC 7517
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// ParseJWKS parses a JWKS document, skipping keys of unsupported types as RFC 7517 suggests
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var raw struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if e := json.Unmarshal(data, &raw); e != nil {
+		return nil, e
+	}
+
+	set := &JWKS{}
+	for i, r := range raw.Keys {
+		k := &JWK{}
+		if e := k.UnmarshalJSON(r); errors.Is(e, errUnsupportedKey) {
+			continue
+		} else if e != nil {
+			return nil, fmt.Errorf("key %d: %w", i, e)
+		}
+		set.Keys = append(set.Keys, k)
+	}
+	return set, nil
+}
+
+// Lookup finds a key by its ID
+func (s *JWKS) Lookup(kid string) (*JWK, bool) {
+	for _, k := range s.Keys {
+		if k.KeyID == kid {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// Public returns the set without private parts
+func (s *JWKS) Public() *JWKS {
+	out := &JWKS{Keys: make([]*JWK, len(s.Keys))}
+	for i, k := range s.Keys {
+		out.Keys[i] = k.Public()
+	}
+	return out
+}
+
+// ServeHTTP serves public keys of the set, usually at "/.well-known/jwks.json"
+func (s *JWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, e := json.Marshal(s.Public())
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Write(b)
+}
+
+func curveSize(c elliptic.Curve) int {
+	return (c.Params().BitSize + 7) / 8
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// encodeInt encodes n in big endian, padded to size bytes if not 0
+func encodeInt(n *big.Int, size int) string {
+	if size == 0 {
+		return encode(n.Bytes())
+	}
+	return encode(n.FillBytes(make([]byte, size)))
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, e := decode(s)
+	if e != nil {
+		return nil, e
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func decodeFixed(s string, size int) (*big.Int, error) {
+	b, e := decode(s)
+	if e != nil {
+		return nil, e
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("%d bytes, want %d", len(b), size)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
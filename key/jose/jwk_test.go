@@ -0,0 +1,129 @@
+package jose
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/supremind/pkg/key"
+)
+
+var keyTypes = []key.KeyType{key.Ed25519, key.ECDSAP256, key.ECDSAP384, key.ECDSAP521, key.RSA}
+
+func TestJWKRoundTrip(t *testing.T) {
+	for _, typ := range keyTypes {
+		t.Run(string(typ), func(t *testing.T) {
+			k, e := GenerateJWK(typ)
+			require.NoError(t, e)
+			assert.True(t, k.IsPrivate())
+			assert.NotEmpty(t, k.KeyID)
+
+			b, e := json.Marshal(k)
+			require.NoError(t, e)
+			var parsed JWK
+			require.NoError(t, json.Unmarshal(b, &parsed))
+			assert.True(t, parsed.IsPrivate())
+			assert.Equal(t, k.KeyID, parsed.KeyID)
+			assert.Equal(t, k.Algorithm, parsed.Algorithm)
+
+			token, e := Sign([]byte("hello"), &parsed, "")
+			require.NoError(t, e)
+			_, payload, e := Verify(token, &JWKS{Keys: []*JWK{k.Public()}})
+			require.NoError(t, e)
+			assert.Equal(t, "hello", string(payload))
+
+			pub := k.Public()
+			assert.False(t, pub.IsPrivate())
+			b, e = json.Marshal(pub)
+			require.NoError(t, e)
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal(b, &fields))
+			for _, f := range []string{"d", "p", "q", "dp", "dq", "qi"} {
+				assert.NotContains(t, fields, f)
+			}
+
+			thumb, e := pub.Thumbprint()
+			require.NoError(t, e)
+			assert.Equal(t, k.KeyID, thumb)
+		})
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// the example of RFC 7638 section 3.1
+	const doc = `{"kty":"RSA","e":"AQAB","alg":"RS256","kid":"2011-04-29",` +
+		`"n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}`
+	var k JWK
+	require.NoError(t, json.Unmarshal([]byte(doc), &k))
+	thumb, e := k.Thumbprint()
+	require.NoError(t, e)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumb)
+	assert.Equal(t, "2011-04-29", k.KeyID)
+}
+
+func TestParseWeakRSA(t *testing.T) {
+	priv, e := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, e)
+	b, e := json.Marshal(&JWK{Key: &priv.PublicKey, Algorithm: "RS256"})
+	require.NoError(t, e)
+
+	var k JWK
+	e = json.Unmarshal(b, &k)
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "rsa key size 1024")
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	const doc = `{"keys":[
+		{"kty":"oct","k":"c2VjcmV0"},
+		{"kty":"OKP","crv":"X25519","x":"hSDwCYkwp1R0i33ctD73Wg2_Og0mOBr066SpjqqbTmo"},
+		{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo","kid":"ed"}
+	]}`
+	set, e := ParseJWKS([]byte(doc))
+	require.NoError(t, e)
+	require.Len(t, set.Keys, 1)
+	k, ok := set.Lookup("ed")
+	require.True(t, ok)
+	assert.False(t, k.IsPrivate())
+
+	_, e = ParseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}]}`))
+	assert.Error(t, e)
+}
+
+func TestJWKSServeHTTP(t *testing.T) {
+	set := &JWKS{}
+	for _, typ := range keyTypes {
+		k, e := GenerateJWK(typ)
+		require.NoError(t, e)
+		set.Keys = append(set.Keys, k)
+	}
+
+	srv := httptest.NewServer(set)
+	defer srv.Close()
+	resp, e := srv.Client().Get(srv.URL)
+	require.NoError(t, e)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/jwk-set+json", resp.Header.Get("Content-Type"))
+	body, e := io.ReadAll(resp.Body)
+	require.NoError(t, e)
+
+	served, e := ParseJWKS(body)
+	require.NoError(t, e)
+	require.Len(t, served.Keys, len(set.Keys))
+	for i, k := range served.Keys {
+		assert.False(t, k.IsPrivate())
+		assert.Equal(t, set.Keys[i].KeyID, k.KeyID)
+
+		token, e := Sign([]byte("{}"), set.Keys[i], "")
+		require.NoError(t, e)
+		_, _, e = Verify(token, served)
+		assert.NoError(t, e)
+	}
+}
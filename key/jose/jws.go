@@ -0,0 +1,222 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha512"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	// ErrMalformedToken is returned for tokens not in the compact serialization
+	ErrMalformedToken = errors.New("malformed token")
+	// ErrUnknownKey is returned if no key in the set matches the kid of a token
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrInvalidSignature is returned for tokens failing signature verification
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Header is the protected header of a JWS
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Type      string `json:"typ,omitempty"`
+	// Critical lists extensions which must be understood, tokens with any are rejected
+	Critical []string `json:"crit,omitempty"`
+}
+
+type algorithm struct {
+	hash crypto.Hash
+	// sign and verify check the type of the key too
+	sign   func(priv crypto.Signer, digest []byte) ([]byte, error)
+	verify func(pub crypto.PublicKey, digest, sig []byte) bool
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": rsaPKCS1(crypto.SHA256),
+	"RS384": rsaPKCS1(crypto.SHA384),
+	"RS512": rsaPKCS1(crypto.SHA512),
+	"PS256": rsaPSS(crypto.SHA256),
+	"PS384": rsaPSS(crypto.SHA384),
+	"PS512": rsaPSS(crypto.SHA512),
+	"ES256": ecdsaAlgorithm(crypto.SHA256, 32),
+	"ES384": ecdsaAlgorithm(crypto.SHA384, 48),
+	"ES512": ecdsaAlgorithm(crypto.SHA512, 66),
+	"EdDSA": {
+		sign: func(priv crypto.Signer, msg []byte) ([]byte, error) {
+			if _, ok := priv.(ed25519.PrivateKey); !ok {
+				return nil, fmt.Errorf("EdDSA with key %T", priv)
+			}
+			return priv.Sign(rand.Reader, msg, crypto.Hash(0))
+		},
+		verify: func(pub crypto.PublicKey, msg, sig []byte) bool {
+			k, ok := pub.(ed25519.PublicKey)
+			return ok && ed25519.Verify(k, msg, sig)
+		},
+	},
+}
+
+func rsaPKCS1(h crypto.Hash) algorithm {
+	return algorithm{
+		hash: h,
+		sign: func(priv crypto.Signer, digest []byte) ([]byte, error) {
+			if _, ok := priv.Public().(*rsa.PublicKey); !ok {
+				return nil, fmt.Errorf("rsa algorithm with key %T", priv)
+			}
+			return priv.Sign(rand.Reader, digest, h)
+		},
+		verify: func(pub crypto.PublicKey, digest, sig []byte) bool {
+			k, ok := pub.(*rsa.PublicKey)
+			return ok && rsa.VerifyPKCS1v15(k, h, digest, sig) == nil
+		},
+	}
+}
+
+func rsaPSS(h crypto.Hash) algorithm {
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: h}
+	return algorithm{
+		hash: h,
+		sign: func(priv crypto.Signer, digest []byte) ([]byte, error) {
+			if _, ok := priv.Public().(*rsa.PublicKey); !ok {
+				return nil, fmt.Errorf("rsa algorithm with key %T", priv)
+			}
+			return priv.Sign(rand.Reader, digest, opts)
+		},
+		verify: func(pub crypto.PublicKey, digest, sig []byte) bool {
+			k, ok := pub.(*rsa.PublicKey)
+			return ok && rsa.VerifyPSS(k, h, digest, sig, opts) == nil
+		},
+	}
+}
+
+// ecdsaAlgorithm signs r and s in fixed size as JWS requires, rather than ASN.1
+func ecdsaAlgorithm(h crypto.Hash, size int) algorithm {
+	return algorithm{
+		hash: h,
+		sign: func(priv crypto.Signer, digest []byte) ([]byte, error) {
+			k, ok := priv.Public().(*ecdsa.PublicKey)
+			if !ok || curveSize(k.Curve) != size {
+				return nil, fmt.Errorf("ecdsa algorithm with key %T", priv)
+			}
+			der, e := priv.Sign(rand.Reader, digest, h)
+			if e != nil {
+				return nil, e
+			}
+
+			var sig struct{ R, S *big.Int }
+			if _, e := asn1.Unmarshal(der, &sig); e != nil {
+				return nil, e
+			}
+			return append(sig.R.FillBytes(make([]byte, size)), sig.S.FillBytes(make([]byte, size))...), nil
+		},
+		verify: func(pub crypto.PublicKey, digest, sig []byte) bool {
+			k, ok := pub.(*ecdsa.PublicKey)
+			if !ok || curveSize(k.Curve) != size || len(sig) != 2*size {
+				return false
+			}
+			r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+			return ecdsa.Verify(k, digest, r, s)
+		},
+	}
+}
+
+func (a algorithm) digest(msg []byte) []byte {
+	if a.hash == 0 {
+		return msg
+	}
+	h := a.hash.New()
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+// Sign signs the payload with the private key into a compact JWS, with the kid and the typ in the header
+func Sign(payload []byte, k *JWK, typ string) (string, error) {
+	priv, ok := k.Key.(crypto.Signer)
+	if !ok {
+		return "", errors.New("sign with a public key")
+	}
+	alg, ok := algorithms[k.Algorithm]
+	if !ok {
+		return "", fmt.Errorf("unsupported algorithm: %q", k.Algorithm)
+	}
+
+	header, e := json.Marshal(Header{Algorithm: k.Algorithm, KeyID: k.KeyID, Type: typ})
+	if e != nil {
+		return "", e
+	}
+	input := encode(header) + "." + encode(payload)
+
+	sig, e := alg.sign(priv, alg.digest([]byte(input)))
+	if e != nil {
+		return "", e
+	}
+	return input + "." + encode(sig), nil
+}
+
+// Verify checks a compact JWS with the key of its kid in the set, or the only key if the token has no kid.
+// The algorithm of the token must be the one of the key, or the default one of its type if the key has none,
+// and "none" is never accepted.
+func Verify(token string, keys *JWKS) (*Header, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, ErrMalformedToken
+	}
+
+	b, e := decode(parts[0])
+	if e != nil {
+		return nil, nil, fmt.Errorf("%w: header: %v", ErrMalformedToken, e)
+	}
+	var header Header
+	if e := json.Unmarshal(b, &header); e != nil {
+		return nil, nil, fmt.Errorf("%w: header: %v", ErrMalformedToken, e)
+	}
+	if len(header.Critical) > 0 {
+		return nil, nil, fmt.Errorf("unsupported critical extensions: %v", header.Critical)
+	}
+
+	payload, e := decode(parts[1])
+	if e != nil {
+		return nil, nil, fmt.Errorf("%w: payload: %v", ErrMalformedToken, e)
+	}
+	sig, e := decode(parts[2])
+	if e != nil {
+		return nil, nil, fmt.Errorf("%w: signature: %v", ErrMalformedToken, e)
+	}
+
+	var k *JWK
+	if keys == nil {
+		return nil, nil, fmt.Errorf("%w: no keys", ErrUnknownKey)
+	} else if header.KeyID != "" {
+		var ok bool
+		if k, ok = keys.Lookup(header.KeyID); !ok {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnknownKey, header.KeyID)
+		}
+	} else if len(keys.Keys) == 1 {
+		k = keys.Keys[0]
+	} else {
+		return nil, nil, fmt.Errorf("%w: no kid", ErrUnknownKey)
+	}
+
+	want := k.Algorithm
+	if want == "" {
+		if want, e = defaultAlgorithm(k.PublicKey()); e != nil {
+			return nil, nil, e
+		}
+	}
+	alg, ok := algorithms[header.Algorithm]
+	if !ok || header.Algorithm != want {
+		return nil, nil, fmt.Errorf("%w: algorithm %q", ErrInvalidSignature, header.Algorithm)
+	}
+	if !alg.verify(k.PublicKey(), alg.digest([]byte(parts[0]+"."+parts[1])), sig) {
+		return nil, nil, ErrInvalidSignature
+	}
+	return &header, payload, nil
+}
@@ -0,0 +1,153 @@
+package jose
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/supremind/pkg/clock"
+)
+
+var (
+	// ErrTokenExpired is returned for tokens past their exp
+	ErrTokenExpired = errors.New("token is expired")
+	// ErrTokenNotValidYet is returned for tokens before their nbf or iat
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	// ErrInvalidIssuer is returned if iss is not the expected one
+	ErrInvalidIssuer = errors.New("invalid issuer")
+	// ErrInvalidAudience is returned if the expected audience is not in aud
+	ErrInvalidAudience = errors.New("invalid audience")
+)
+
+// NumericDate is a time in seconds since the epoch, see RFC 7519
+type NumericDate struct {
+	time.Time
+}
+
+// NewNumericDate truncates t to seconds
+func NewNumericDate(t time.Time) *NumericDate {
+	return &NumericDate{t.Truncate(time.Second)}
+}
+
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(d.Unix(), 10)), nil
+}
+
+func (d *NumericDate) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if e := json.Unmarshal(b, &n); e != nil {
+		return fmt.Errorf("numeric date: %w", e)
+	}
+	f, e := n.Float64()
+	if e != nil {
+		return fmt.Errorf("numeric date: %w", e)
+	}
+
+	sec := int64(f)
+	d.Time = time.Unix(sec, int64((f-float64(sec))*1e9))
+	return nil
+}
+
+// Audience is a list of audiences, encoded as a string if there is only one
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		*a = Audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// Contains tells if aud is one of the audiences
+func (a Audience) Contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the registered claims of JWT, which could be embedded in custom claims
+type Claims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+}
+
+// SignJWT signs claims encoded in JSON, which usually embed Claims
+func SignJWT(claims interface{}, k *JWK) (string, error) {
+	payload, e := json.Marshal(claims)
+	if e != nil {
+		return "", e
+	}
+	return Sign(payload, k, "JWT")
+}
+
+// Validator verifies JWTs and validates their claims
+type Validator struct {
+	// Keys verify signatures, selected by kid, see Verify
+	Keys *JWKS
+	// Issuer is required to be iss if not empty
+	Issuer string
+	// Audience is required to be one of aud if not empty
+	Audience string
+	// Leeway tolerates clock skew for exp, nbf and iat
+	Leeway time.Duration
+}
+
+// Parse verifies the token and validates its registered claims, then decodes its payload into claims if not nil.
+// Tokens without exp are rejected. It checks times by the clock carried by ctx, see clock.With.
+func (v *Validator) Parse(ctx context.Context, token string, claims interface{}) (*Claims, error) {
+	header, payload, e := Verify(token, v.Keys)
+	if e != nil {
+		return nil, e
+	}
+	if header.Type != "" && header.Type != "JWT" {
+		return nil, fmt.Errorf("%w: typ %q", ErrMalformedToken, header.Type)
+	}
+
+	var registered Claims
+	if e := json.Unmarshal(payload, &registered); e != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrMalformedToken, e)
+	}
+
+	now := clock.From(ctx).Now()
+	switch {
+	case registered.ExpiresAt == nil:
+		return nil, fmt.Errorf("%w: no exp", ErrMalformedToken)
+	case !now.Before(registered.ExpiresAt.Add(v.Leeway)):
+		return nil, fmt.Errorf("%w: at %s", ErrTokenExpired, registered.ExpiresAt.UTC())
+	case registered.NotBefore != nil && now.Add(v.Leeway).Before(registered.NotBefore.Time):
+		return nil, fmt.Errorf("%w: until %s", ErrTokenNotValidYet, registered.NotBefore.UTC())
+	case registered.IssuedAt != nil && now.Add(v.Leeway).Before(registered.IssuedAt.Time):
+		return nil, fmt.Errorf("%w: issued at %s", ErrTokenNotValidYet, registered.IssuedAt.UTC())
+	case v.Issuer != "" && registered.Issuer != v.Issuer:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidIssuer, registered.Issuer)
+	case v.Audience != "" && !registered.Audience.Contains(v.Audience):
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAudience, registered.Audience)
+	}
+
+	if claims != nil {
+		if e := json.Unmarshal(payload, claims); e != nil {
+			return nil, fmt.Errorf("%w: claims: %v", ErrMalformedToken, e)
+		}
+	}
+	return &registered, nil
+}
@@ -0,0 +1,177 @@
+package jose
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/supremind/pkg/clock"
+	"github.com/supremind/pkg/key"
+)
+
+type testClaims struct {
+	Claims
+	Scope string `json:"scope"`
+}
+
+func TestAudience(t *testing.T) {
+	b, e := json.Marshal(Audience{"a"})
+	require.NoError(t, e)
+	assert.Equal(t, `"a"`, string(b))
+	b, e = json.Marshal(Audience{"a", "b"})
+	require.NoError(t, e)
+	assert.Equal(t, `["a","b"]`, string(b))
+
+	var aud Audience
+	require.NoError(t, json.Unmarshal([]byte(`"a"`), &aud))
+	assert.Equal(t, Audience{"a"}, aud)
+	require.NoError(t, json.Unmarshal([]byte(`["a","b"]`), &aud))
+	assert.True(t, aud.Contains("b"))
+	assert.False(t, aud.Contains("c"))
+}
+
+func TestValidator(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ctx := clock.With(context.Background(), clock.NewManual(now))
+
+	k, e := GenerateJWK(key.ECDSAP256)
+	require.NoError(t, e)
+	v := &Validator{
+		Keys:     (&JWKS{Keys: []*JWK{k}}).Public(),
+		Issuer:   "https://issuer",
+		Audience: "api",
+		Leeway:   time.Minute,
+	}
+
+	claims := testClaims{
+		Claims: Claims{
+			Issuer:    "https://issuer",
+			Subject:   "alice",
+			Audience:  Audience{"api", "web"},
+			ExpiresAt: NewNumericDate(now.Add(time.Hour)),
+			NotBefore: NewNumericDate(now),
+			IssuedAt:  NewNumericDate(now),
+		},
+		Scope: "read",
+	}
+	token, e := SignJWT(claims, k)
+	require.NoError(t, e)
+
+	var got testClaims
+	registered, e := v.Parse(ctx, token, &got)
+	require.NoError(t, e)
+	assert.Equal(t, "alice", registered.Subject)
+	assert.Equal(t, "read", got.Scope)
+	assert.True(t, now.Add(time.Hour).Equal(got.ExpiresAt.Time))
+
+	// nbf, iat and exp tolerate clock skew within the leeway
+	clk := clock.NewManual(now.Add(-90 * time.Second))
+	skewed := clock.With(context.Background(), clk)
+	_, e = v.Parse(skewed, token, nil)
+	assert.True(t, errors.Is(e, ErrTokenNotValidYet))
+	clk.Advance(time.Minute)
+	_, e = v.Parse(skewed, token, nil)
+	assert.NoError(t, e)
+	clk.Advance(time.Hour + time.Minute)
+	_, e = v.Parse(skewed, token, nil)
+	assert.NoError(t, e)
+	clk.Advance(time.Minute)
+	_, e = v.Parse(skewed, token, nil)
+	assert.True(t, errors.Is(e, ErrTokenExpired))
+
+	_, e = (&Validator{Keys: v.Keys, Issuer: "https://other"}).Parse(ctx, token, nil)
+	assert.True(t, errors.Is(e, ErrInvalidIssuer))
+	_, e = (&Validator{Keys: v.Keys, Audience: "other"}).Parse(ctx, token, nil)
+	assert.True(t, errors.Is(e, ErrInvalidAudience))
+
+	_, e = (&Validator{}).Parse(ctx, token, nil)
+	assert.True(t, errors.Is(e, ErrUnknownKey))
+
+	claims.ExpiresAt = nil
+	token, e = SignJWT(claims, k)
+	require.NoError(t, e)
+	_, e = v.Parse(ctx, token, nil)
+	assert.True(t, errors.Is(e, ErrMalformedToken))
+}
+
+func TestVerifyKeySelection(t *testing.T) {
+	old, e := GenerateJWK(key.RSA)
+	require.NoError(t, e)
+	current, e := GenerateJWK(key.Ed25519)
+	require.NoError(t, e)
+	keys := (&JWKS{Keys: []*JWK{old, current}}).Public()
+
+	for _, k := range []*JWK{old, current} {
+		token, e := Sign([]byte("{}"), k, "JWT")
+		require.NoError(t, e)
+		header, _, e := Verify(token, keys)
+		require.NoError(t, e)
+		assert.Equal(t, k.KeyID, header.KeyID)
+		assert.Equal(t, k.Algorithm, header.Algorithm)
+	}
+
+	// rotated out
+	token, e := Sign([]byte("{}"), old, "JWT")
+	require.NoError(t, e)
+	_, _, e = Verify(token, (&JWKS{Keys: []*JWK{current}}).Public())
+	assert.True(t, errors.Is(e, ErrUnknownKey))
+
+	// without kid, only a single key is acceptable
+	anonymous := *current
+	anonymous.KeyID = ""
+	token, e = Sign([]byte("{}"), &anonymous, "JWT")
+	require.NoError(t, e)
+	_, _, e = Verify(token, keys)
+	assert.True(t, errors.Is(e, ErrUnknownKey))
+	_, _, e = Verify(token, &JWKS{Keys: []*JWK{current.Public()}})
+	assert.NoError(t, e)
+}
+
+func TestVerifyRejects(t *testing.T) {
+	k, e := GenerateJWK(key.ECDSAP384)
+	require.NoError(t, e)
+	keys := &JWKS{Keys: []*JWK{k.Public()}}
+	token, e := Sign([]byte(`{"sub":"alice"}`), k, "JWT")
+	require.NoError(t, e)
+	parts := strings.Split(token, ".")
+
+	enc := base64.RawURLEncoding.EncodeToString
+	for name, tampered := range map[string]string{
+		"payload":   parts[0] + "." + enc([]byte(`{"sub":"admin"}`)) + "." + parts[2],
+		"signature": parts[0] + "." + parts[1] + "." + enc(make([]byte, 96)),
+		"none":      enc([]byte(`{"alg":"none","kid":"`+k.KeyID+`"}`)) + "." + parts[1] + ".",
+		"alg":       enc([]byte(`{"alg":"ES256","kid":"`+k.KeyID+`"}`)) + "." + parts[1] + "." + parts[2],
+	} {
+		_, _, e := Verify(tampered, keys)
+		assert.True(t, errors.Is(e, ErrInvalidSignature), name)
+	}
+
+	_, _, e = Verify(parts[0]+"."+parts[1], keys)
+	assert.True(t, errors.Is(e, ErrMalformedToken))
+	crit := enc([]byte(`{"alg":"ES384","kid":"`+k.KeyID+`","crit":["exp"]}`)) + "." + parts[1] + "." + parts[2]
+	_, _, e = Verify(crit, keys)
+	assert.Error(t, e)
+
+	// keys without an algorithm verify only the default one of their type
+	rsaKey, e := GenerateJWK(key.RSA)
+	require.NoError(t, e)
+	public := rsaKey.Public()
+	public.Algorithm = ""
+	keys = &JWKS{Keys: []*JWK{public}}
+	token, e = Sign([]byte(`{"sub":"alice"}`), rsaKey, "JWT")
+	require.NoError(t, e)
+	_, _, e = Verify(token, keys)
+	assert.NoError(t, e)
+	rsaKey.Algorithm = "PS256"
+	token, e = Sign([]byte(`{"sub":"alice"}`), rsaKey, "JWT")
+	require.NoError(t, e)
+	_, _, e = Verify(token, keys)
+	assert.True(t, errors.Is(e, ErrInvalidSignature), "%v", e)
+}
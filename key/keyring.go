@@ -0,0 +1,455 @@
+package key
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	// ErrUnknownKey is returned when decrypting with a key not in the keyring
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrDecrypt is returned for ciphertexts tampered, or encrypted with other associated data
+	ErrDecrypt = errors.New("message authentication failed")
+	// ErrMalformed is returned for data not in the ciphertext format
+	ErrMalformed = errors.New("malformed ciphertext")
+)
+
+// Cipher is an AEAD algorithm with 256-bit keys
+type Cipher byte
+
+const (
+	AES256GCM         Cipher = 1
+	XChaCha20Poly1305 Cipher = 2
+)
+
+// symmetricKeySize is the key size of all ciphers
+const symmetricKeySize = 32
+
+var cipherNames = map[Cipher]string{
+	AES256GCM:         "aes-256-gcm",
+	XChaCha20Poly1305: "xchacha20-poly1305",
+}
+
+func (c Cipher) String() string {
+	if name, ok := cipherNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("cipher(%d)", byte(c))
+}
+
+func (c Cipher) MarshalText() ([]byte, error) {
+	if _, ok := cipherNames[c]; !ok {
+		return nil, fmt.Errorf("unsupported cipher: %s", c)
+	}
+	return []byte(c.String()), nil
+}
+
+func (c *Cipher) UnmarshalText(b []byte) error {
+	for k, name := range cipherNames {
+		if name == string(b) {
+			*c = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported cipher: %s", b)
+}
+
+func (c Cipher) aead(secret []byte) (cipher.AEAD, error) {
+	switch c {
+	case AES256GCM:
+		block, e := aes.NewCipher(secret)
+		if e != nil {
+			return nil, e
+		}
+		return cipher.NewGCM(block)
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(secret)
+	default:
+		return nil, fmt.Errorf("unsupported cipher: %s", c)
+	}
+}
+
+// SymmetricKey is a version of a key in a Keyring
+type SymmetricKey struct {
+	Version uint32 `json:"version"`
+	Cipher  Cipher `json:"cipher"`
+	Secret  []byte `json:"secret"`
+}
+
+// NewSymmetricKey generates a random key
+func NewSymmetricKey(version uint32, c Cipher) (*SymmetricKey, error) {
+	if _, ok := cipherNames[c]; !ok {
+		return nil, fmt.Errorf("unsupported cipher: %s", c)
+	}
+
+	secret := make([]byte, symmetricKeySize)
+	if _, e := rand.Read(secret); e != nil {
+		return nil, e
+	}
+	return &SymmetricKey{Version: version, Cipher: c, Secret: secret}, nil
+}
+
+// Keyring keeps versions of a key identified by its ID.
+// It encrypts with the primary version, which is the latest after Rotate, and decrypts with any version,
+// so data encrypted before rotations still decrypts.
+// It could be marshaled into JSON, with secrets in plain, and saved in a Store.
+type Keyring struct {
+	mu      sync.RWMutex
+	id      string
+	primary uint32
+	keys    map[uint32]*SymmetricKey
+}
+
+// NewKeyring creates a keyring with a random key of version 1.
+// The ID is written in ciphertexts, at most 255 bytes.
+func NewKeyring(id string, c Cipher) (*Keyring, error) {
+	if len(id) == 0 || len(id) > 255 {
+		return nil, fmt.Errorf("invalid key id: %q", id)
+	}
+	k, e := NewSymmetricKey(1, c)
+	if e != nil {
+		return nil, e
+	}
+	return &Keyring{id: id, primary: 1, keys: map[uint32]*SymmetricKey{1: k}}, nil
+}
+
+// ID returns the key ID
+func (r *Keyring) ID() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.id
+}
+
+// Primary returns the version encrypting new data
+func (r *Keyring) Primary() uint32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.primary
+}
+
+// Versions returns all versions in order
+func (r *Keyring) Versions() []uint32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]uint32, 0, len(r.keys))
+	for v := range r.keys {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Rotate adds a new version with the cipher and makes it primary
+func (r *Keyring) Rotate(c Cipher) (uint32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var latest uint32
+	for v := range r.keys {
+		if v > latest {
+			latest = v
+		}
+	}
+	k, e := NewSymmetricKey(latest+1, c)
+	if e != nil {
+		return 0, e
+	}
+	r.keys[k.Version] = k
+	r.primary = k.Version
+	return k.Version, nil
+}
+
+// Retire removes a version, data encrypted by it could not be decrypted anymore, see Rewrap.
+// The primary version could not be retired.
+func (r *Keyring) Retire(version uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if version == r.primary {
+		return fmt.Errorf("retire primary version %d of key %s", version, r.id)
+	}
+	if _, ok := r.keys[version]; !ok {
+		return fmt.Errorf("%w: %s version %d", ErrUnknownKey, r.id, version)
+	}
+	delete(r.keys, version)
+	return nil
+}
+
+// key returns the ID with a version of the keyring, the primary one for 0, they are read together
+// as UnmarshalJSON could replace both
+func (r *Keyring) key(version uint32) (string, *SymmetricKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if version == 0 {
+		version = r.primary
+	}
+	k, ok := r.keys[version]
+	if !ok {
+		return r.id, nil, fmt.Errorf("%w: %s version %d", ErrUnknownKey, r.id, version)
+	}
+	return r.id, k, nil
+}
+
+type keyringJSON struct {
+	ID      string          `json:"id"`
+	Primary uint32          `json:"primary"`
+	Keys    []*SymmetricKey `json:"keys"`
+}
+
+func (r *Keyring) MarshalJSON() ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := keyringJSON{ID: r.id, Primary: r.primary}
+	for _, k := range r.keys {
+		out.Keys = append(out.Keys, k)
+	}
+	sort.Slice(out.Keys, func(i, j int) bool { return out.Keys[i].Version < out.Keys[j].Version })
+	return json.Marshal(out)
+}
+
+func (r *Keyring) UnmarshalJSON(b []byte) error {
+	var in keyringJSON
+	if e := json.Unmarshal(b, &in); e != nil {
+		return e
+	}
+	if len(in.ID) == 0 || len(in.ID) > 255 {
+		return fmt.Errorf("invalid key id: %q", in.ID)
+	}
+
+	keys := make(map[uint32]*SymmetricKey, len(in.Keys))
+	for i, k := range in.Keys {
+		if k == nil {
+			return fmt.Errorf("key %s has a null entry at %d", in.ID, i)
+		}
+		if _, ok := keys[k.Version]; ok {
+			return fmt.Errorf("duplicate version %d of key %s", k.Version, in.ID)
+		}
+		if len(k.Secret) != symmetricKeySize {
+			return fmt.Errorf("invalid secret size of key %s version %d", in.ID, k.Version)
+		}
+		if _, e := k.Cipher.aead(k.Secret); e != nil {
+			return e
+		}
+		keys[k.Version] = k
+	}
+	if _, ok := keys[in.Primary]; !ok {
+		return fmt.Errorf("primary version %d of key %s not found", in.Primary, in.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.id, r.primary, r.keys = in.ID, in.Primary, keys
+	return nil
+}
+
+// The ciphertext format, all integers in big endian:
+//
+//	format  byte   ciphertextFormat
+//	mode    byte   modeDirect or modeEnvelope
+//	cipher  byte   cipher of the payload
+//	idLen   byte
+//	id      [idLen]byte
+//	version uint32 version of the key in the keyring
+//	for modeEnvelope only:
+//	  wrappedLen uint16
+//	  wrapped    [wrappedLen]byte  nonce and the data key sealed by the keyring, with the header so far as associated data
+//	nonce   [nonce size of cipher]byte
+//	sealed  []byte payload sealed with the whole header and the associated data given
+const (
+	ciphertextFormat = 1
+
+	modeDirect   = 1
+	modeEnvelope = 2
+)
+
+// Encrypt seals the plaintext with the primary key, the same associated data is required to decrypt
+func (r *Keyring) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
+	id, k, e := r.key(0)
+	if e != nil {
+		return nil, e
+	}
+
+	header := makeHeader(id, modeDirect, k.Cipher, k.Version)
+	return seal(header, k.Cipher, k.Secret, plaintext, associatedData)
+}
+
+// EncryptEnvelope seals the plaintext with a random data key of the cipher, which is sealed by the primary key.
+// Every message gets its own data key, so the keyring only ever seals small random keys.
+func (r *Keyring) EncryptEnvelope(c Cipher, plaintext, associatedData []byte) ([]byte, error) {
+	dataKey, e := NewSymmetricKey(0, c)
+	if e != nil {
+		return nil, e
+	}
+	id, k, e := r.key(0)
+	if e != nil {
+		return nil, e
+	}
+
+	header, e := wrap(id, k, c, dataKey.Secret)
+	if e != nil {
+		return nil, e
+	}
+	return seal(header, c, dataKey.Secret, plaintext, associatedData)
+}
+
+func makeHeader(id string, mode byte, c Cipher, version uint32) []byte {
+	header := []byte{ciphertextFormat, mode, byte(c), byte(len(id))}
+	header = append(header, id...)
+	return binary.BigEndian.AppendUint32(header, version)
+}
+
+// wrap returns the header of an envelope, with the data key sealed by k
+func wrap(id string, k *SymmetricKey, c Cipher, dataKey []byte) ([]byte, error) {
+	header := makeHeader(id, modeEnvelope, c, k.Version)
+	wrapped, e := seal(nil, k.Cipher, k.Secret, dataKey, header)
+	if e != nil {
+		return nil, e
+	}
+
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	return append(header, wrapped...), nil
+}
+
+// seal appends the nonce and the sealed plaintext to the header
+func seal(header []byte, c Cipher, secret, plaintext, associatedData []byte) ([]byte, error) {
+	aead, e := c.aead(secret)
+	if e != nil {
+		return nil, e
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, e := rand.Read(nonce); e != nil {
+		return nil, e
+	}
+
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plaintext, append(header[:len(header):len(header)], associatedData...)), nil
+}
+
+func open(header, body []byte, c Cipher, secret, associatedData []byte) ([]byte, error) {
+	aead, e := c.aead(secret)
+	if e != nil {
+		return nil, e
+	}
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrMalformed
+	}
+
+	nonce, sealed := body[:aead.NonceSize()], body[aead.NonceSize():]
+	plaintext, e := aead.Open(nil, nonce, sealed, append(header[:len(header):len(header)], associatedData...))
+	if e != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// ciphertext is a parsed ciphertext
+type ciphertext struct {
+	mode    byte
+	cipher  Cipher
+	id      string
+	version uint32
+	// prefix is the header before the wrapped key
+	prefix  []byte
+	wrapped []byte
+	header  []byte
+	body    []byte
+}
+
+func parseCiphertext(b []byte) (*ciphertext, error) {
+	if len(b) < 4 || b[0] != ciphertextFormat {
+		return nil, ErrMalformed
+	}
+
+	c := &ciphertext{mode: b[1], cipher: Cipher(b[2])}
+	idLen := int(b[3])
+	if len(b) < 4+idLen+4 {
+		return nil, ErrMalformed
+	}
+	c.id = string(b[4 : 4+idLen])
+	c.version = binary.BigEndian.Uint32(b[4+idLen:])
+	n := 4 + idLen + 4
+	c.prefix = b[:n]
+
+	switch c.mode {
+	case modeDirect:
+	case modeEnvelope:
+		if len(b) < n+2 {
+			return nil, ErrMalformed
+		}
+		wrappedLen := int(binary.BigEndian.Uint16(b[n:]))
+		n += 2
+		if len(b) < n+wrappedLen {
+			return nil, ErrMalformed
+		}
+		c.wrapped = b[n : n+wrappedLen]
+		n += wrappedLen
+	default:
+		return nil, ErrMalformed
+	}
+
+	c.header, c.body = b[:n], b[n:]
+	return c, nil
+}
+
+// Decrypt opens a ciphertext of Encrypt or EncryptEnvelope, with any version of the keyring
+func (r *Keyring) Decrypt(b, associatedData []byte) ([]byte, error) {
+	c, e := parseCiphertext(b)
+	if e != nil {
+		return nil, e
+	}
+	dataKey, e := r.dataKey(c)
+	if e != nil {
+		return nil, e
+	}
+	return open(c.header, c.body, c.cipher, dataKey, associatedData)
+}
+
+// dataKey returns the key sealing the payload
+func (r *Keyring) dataKey(c *ciphertext) ([]byte, error) {
+	id, k, e := r.key(c.version)
+	if c.id != id {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, c.id)
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	if c.mode == modeDirect {
+		if c.cipher != k.Cipher {
+			return nil, ErrMalformed
+		}
+		return k.Secret, nil
+	}
+	return open(c.prefix, c.wrapped, k.Cipher, k.Secret, nil)
+}
+
+// Rewrap reseals a ciphertext of Encrypt or EncryptEnvelope with the primary key,
+// so that older versions could be retired after all data is rewrapped
+func (r *Keyring) Rewrap(b, associatedData []byte) ([]byte, error) {
+	c, e := parseCiphertext(b)
+	if e != nil {
+		return nil, e
+	}
+	plaintext, e := r.Decrypt(b, associatedData)
+	if e != nil {
+		return nil, e
+	}
+
+	if c.mode == modeEnvelope {
+		return r.EncryptEnvelope(c.cipher, plaintext, associatedData)
+	}
+	return r.Encrypt(plaintext, associatedData)
+}
@@ -0,0 +1,143 @@
+package key
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring(t *testing.T) {
+	for _, c := range []Cipher{AES256GCM, XChaCha20Poly1305} {
+		t.Run(c.String(), func(t *testing.T) {
+			r, e := NewKeyring("config", c)
+			require.NoError(t, e)
+			secret, ad := []byte("s3cr3t-token"), []byte("database.password")
+
+			direct, e := r.Encrypt(secret, ad)
+			require.NoError(t, e)
+			envelope, e := r.EncryptEnvelope(XChaCha20Poly1305, secret, ad)
+			require.NoError(t, e)
+
+			for _, b := range [][]byte{direct, envelope} {
+				plaintext, e := r.Decrypt(b, ad)
+				require.NoError(t, e)
+				assert.Equal(t, secret, plaintext)
+
+				_, e = r.Decrypt(b, []byte("other"))
+				assert.True(t, errors.Is(e, ErrDecrypt), "%v", e)
+
+				tampered := append([]byte{}, b...)
+				tampered[len(tampered)-1] ^= 1
+				_, e = r.Decrypt(tampered, ad)
+				assert.True(t, errors.Is(e, ErrDecrypt), "%v", e)
+
+				_, e = r.Decrypt(b[:len(b)-20], ad)
+				assert.Error(t, e)
+			}
+
+			other, e := NewKeyring("tokens", c)
+			require.NoError(t, e)
+			_, e = other.Decrypt(direct, ad)
+			assert.True(t, errors.Is(e, ErrUnknownKey), "%v", e)
+
+			_, e = r.Decrypt([]byte("plain"), ad)
+			assert.True(t, errors.Is(e, ErrMalformed), "%v", e)
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	r, e := NewKeyring("config", AES256GCM)
+	require.NoError(t, e)
+
+	old, e := r.Encrypt([]byte("v1"), nil)
+	require.NoError(t, e)
+	oldEnvelope, e := r.EncryptEnvelope(AES256GCM, []byte("v1"), nil)
+	require.NoError(t, e)
+
+	v, e := r.Rotate(XChaCha20Poly1305)
+	require.NoError(t, e)
+	assert.Equal(t, uint32(2), v)
+	assert.Equal(t, uint32(2), r.Primary())
+	assert.Equal(t, []uint32{1, 2}, r.Versions())
+	assert.Error(t, r.Retire(2))
+
+	// old data still decrypts after rotation
+	plaintext, e := r.Decrypt(old, nil)
+	require.NoError(t, e)
+	assert.Equal(t, []byte("v1"), plaintext)
+
+	// keyrings survive json
+	b, e := json.Marshal(r)
+	require.NoError(t, e)
+	var loaded Keyring
+	require.NoError(t, json.Unmarshal(b, &loaded))
+	assert.Equal(t, "config", loaded.ID())
+	assert.Equal(t, uint32(2), loaded.Primary())
+
+	rewrapped, e := loaded.Rewrap(old, nil)
+	require.NoError(t, e)
+	rewrappedEnvelope, e := loaded.Rewrap(oldEnvelope, nil)
+	require.NoError(t, e)
+
+	require.NoError(t, loaded.Retire(1))
+	_, e = loaded.Decrypt(old, nil)
+	assert.True(t, errors.Is(e, ErrUnknownKey), "%v", e)
+	for _, b := range [][]byte{rewrapped, rewrappedEnvelope} {
+		plaintext, e := loaded.Decrypt(b, nil)
+		require.NoError(t, e)
+		assert.Equal(t, []byte("v1"), plaintext)
+	}
+
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"config","primary":3,"keys":[]}`), &loaded))
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"config","primary":1,"keys":[{"version":1,"cipher":"des","secret":"AAAA"}]}`), &loaded))
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"config","primary":1,"keys":[null]}`), &loaded))
+	const key1 = `{"version":1,"cipher":"aes-256-gcm","secret":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}`
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"config","primary":1,"keys":[`+key1+`]}`), &loaded))
+	e = json.Unmarshal([]byte(`{"id":"config","primary":1,"keys":[`+key1+`,`+key1+`]}`), &loaded)
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "duplicate version 1")
+	}
+
+	_, e = NewKeyring("", AES256GCM)
+	assert.Error(t, e)
+}
+
+func TestKeyringReload(t *testing.T) {
+	saved := make(map[string][]byte)
+	for _, id := range []string{"a", "b"} {
+		r, e := NewKeyring(id, AES256GCM)
+		require.NoError(t, e)
+		saved[id], e = json.Marshal(r)
+		require.NoError(t, e)
+	}
+
+	var r Keyring
+	require.NoError(t, json.Unmarshal(saved["a"], &r))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = json.Unmarshal(saved[[]string{"a", "b"}[i%2]], &r)
+		}
+	}()
+
+	// ciphertexts are sealed by the key of the id written in them, whichever keyring is loaded
+	for i := 0; i < 100; i++ {
+		b, e := r.Encrypt([]byte("secret"), nil)
+		require.NoError(t, e)
+		c, e := parseCiphertext(b)
+		require.NoError(t, e)
+
+		var loaded Keyring
+		require.NoError(t, json.Unmarshal(saved[c.id], &loaded))
+		_, e = loaded.Decrypt(b, nil)
+		assert.NoError(t, e)
+		assert.NotEmpty(t, r.ID())
+	}
+	<-done
+}
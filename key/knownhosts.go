@@ -0,0 +1,475 @@
+package key
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/supremind/pkg/internal/atomicfile"
+)
+
+const (
+	// MarkerCertAuthority marks a CA key trusted for host certificates of the patterns
+	MarkerCertAuthority = "@cert-authority"
+	// MarkerRevoked marks a key never to be trusted
+	MarkerRevoked = "@revoked"
+)
+
+// KnownHost is an entry of known_hosts
+type KnownHost struct {
+	// Marker is MarkerCertAuthority, MarkerRevoked or empty
+	Marker string
+	// Patterns are host names like "example.com" or "[example.com]:2222", which could be hashed,
+	// have wildcards "*" and "?", or be negated by "!"
+	Patterns []string
+	Key      ssh.PublicKey
+	Comment  string
+}
+
+// Line formats the entry as a line of known_hosts, without the line break
+func (h *KnownHost) Line() string {
+	var fields []string
+	if h.Marker != "" {
+		fields = append(fields, h.Marker)
+	}
+	fields = append(fields, strings.Join(h.Patterns, ","), strings.TrimSpace(string(ssh.MarshalAuthorizedKey(h.Key))))
+	if h.Comment != "" {
+		fields = append(fields, h.Comment)
+	}
+	return strings.Join(fields, " ")
+}
+
+// Match tells if the host, like "example.com" or "example.com:2222", matches the patterns
+func (h *KnownHost) Match(host string) bool {
+	address := normalizeHost(host)
+
+	matched := false
+	for _, p := range h.Patterns {
+		negated := strings.HasPrefix(p, "!")
+		if negated {
+			p = p[1:]
+		}
+		if matchHostPattern(p, address) {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+func normalizeHost(host string) string {
+	return strings.ToLower(knownhosts.Normalize(host))
+}
+
+func matchHostPattern(pattern, address string) bool {
+	if strings.HasPrefix(pattern, "|") {
+		return matchHashedHost(pattern, address)
+	}
+	return matchWildcard(strings.ToLower(pattern), address)
+}
+
+// matchHashedHost matches hashed patterns like "|1|salt|hash" of ssh-keygen -H
+func matchHashedHost(pattern, address string) bool {
+	parts := strings.Split(pattern, "|")
+	if len(parts) != 4 || parts[1] != "1" {
+		return false
+	}
+	salt, e := base64.StdEncoding.DecodeString(parts[2])
+	if e != nil {
+		return false
+	}
+	hash, e := base64.StdEncoding.DecodeString(parts[3])
+	if e != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(address))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+func matchWildcard(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchWildcard(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+func hasWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?!")
+}
+
+// KnownHosts is the content of a known_hosts file, keeping comments and blank lines.
+// It is safe for concurrent use.
+type KnownHosts struct {
+	mu    sync.Mutex
+	path  string
+	lines []knownHostsLine
+}
+
+// knownHostsLine is either an entry or a raw line of comment or blank
+type knownHostsLine struct {
+	raw  string
+	host *KnownHost
+}
+
+// ParseKnownHosts parses the content of a known_hosts file
+func ParseKnownHosts(data []byte) (*KnownHosts, error) {
+	k := &KnownHosts{}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, 1<<20)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			k.lines = append(k.lines, knownHostsLine{raw: line})
+			continue
+		}
+
+		marker, hosts, key, comment, _, e := ssh.ParseKnownHosts([]byte(trimmed))
+		if e != nil {
+			return nil, fmt.Errorf("line %d: %w", n, e)
+		}
+		if marker != "" {
+			marker = "@" + marker
+		}
+		k.lines = append(k.lines, knownHostsLine{host: &KnownHost{Marker: marker, Patterns: hosts, Key: key, Comment: comment}})
+	}
+	return k, s.Err()
+}
+
+// LoadKnownHostsFile reads a known_hosts file, which is empty if it does not exist.
+// The file is written back by Save.
+func LoadKnownHostsFile(path string) (*KnownHosts, error) {
+	data, e := ioutil.ReadFile(path)
+	if e != nil && !os.IsNotExist(e) {
+		return nil, e
+	}
+
+	k, e := ParseKnownHosts(data)
+	if e != nil {
+		return nil, fmt.Errorf("%s: %w", path, e)
+	}
+	k.path = path
+	return k, nil
+}
+
+// Save writes the content atomically back to the file loaded by LoadKnownHostsFile
+func (k *KnownHosts) Save() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.save()
+}
+
+func (k *KnownHosts) save() error {
+	if k.path == "" {
+		return fmt.Errorf("known hosts is not loaded from a file")
+	}
+	return atomicfile.Write(k.path, k.marshal(), 0644, true)
+}
+
+// Marshal formats the content as a known_hosts file
+func (k *KnownHosts) Marshal() []byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.marshal()
+}
+
+func (k *KnownHosts) marshal() []byte {
+	var b bytes.Buffer
+	for _, l := range k.lines {
+		if l.host != nil {
+			b.WriteString(l.host.Line())
+		} else {
+			b.WriteString(l.raw)
+		}
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// Entries returns all entries in order
+func (k *KnownHosts) Entries() []KnownHost {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var out []KnownHost
+	for _, l := range k.lines {
+		if l.host != nil {
+			out = append(out, *l.host)
+		}
+	}
+	return out
+}
+
+// Lookup returns keys of the host, without markers
+func (k *KnownHosts) Lookup(host string) []ssh.PublicKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var out []ssh.PublicKey
+	for _, l := range k.lines {
+		if l.host != nil && l.host.Marker == "" && l.host.Match(host) {
+			out = append(out, l.host.Key)
+		}
+	}
+	return out
+}
+
+// Add adds the key of hosts like "example.com" or "10.0.0.1:2222", unless the key is known for them
+func (k *KnownHosts) Add(hosts []string, key ssh.PublicKey) {
+	k.add(hosts, key, false)
+}
+
+// AddHashed adds the key of hosts like Add, with host names hashed
+func (k *KnownHosts) AddHashed(hosts []string, key ssh.PublicKey) {
+	k.add(hosts, key, true)
+}
+
+func (k *KnownHosts) add(hosts []string, key ssh.PublicKey, hash bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var patterns []string
+	for _, h := range hosts {
+		if k.known(h, key) {
+			continue
+		}
+		p := normalizeHost(h)
+		if hash {
+			p = knownhosts.HashHostname(p)
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		return
+	}
+
+	if hash {
+		// one hashed host a line, like ssh-keygen -H
+		for _, p := range patterns {
+			k.lines = append(k.lines, knownHostsLine{host: &KnownHost{Patterns: []string{p}, Key: key}})
+		}
+		return
+	}
+	k.lines = append(k.lines, knownHostsLine{host: &KnownHost{Patterns: patterns, Key: key}})
+}
+
+func (k *KnownHosts) known(host string, key ssh.PublicKey) bool {
+	for _, l := range k.lines {
+		if l.host != nil && l.host.Marker == "" && l.host.Match(host) && keysEqual(l.host.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddCertAuthority trusts the CA for host certificates of hosts matching the patterns, like "*.example.com"
+func (k *KnownHosts) AddCertAuthority(patterns []string, ca ssh.PublicKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.lines = append(k.lines, knownHostsLine{host: &KnownHost{Marker: MarkerCertAuthority, Patterns: patterns, Key: ca}})
+}
+
+// Revoke marks the key as revoked for all hosts
+func (k *KnownHosts) Revoke(key ssh.PublicKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.lines = append(k.lines, knownHostsLine{host: &KnownHost{Marker: MarkerRevoked, Patterns: []string{"*"}, Key: key}})
+}
+
+// Remove removes the host from entries without markers, like ssh-keygen -R.
+// Patterns with wildcards are kept, and entries left with no patterns are removed.
+// It returns the number of patterns removed.
+func (k *KnownHosts) Remove(host string) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	address := normalizeHost(host)
+	removed := 0
+	lines := k.lines[:0]
+	for _, l := range k.lines {
+		if l.host == nil || l.host.Marker != "" {
+			lines = append(lines, l)
+			continue
+		}
+
+		var patterns []string
+		for _, p := range l.host.Patterns {
+			if !hasWildcard(p) && matchHostPattern(p, address) {
+				removed++
+				continue
+			}
+			patterns = append(patterns, p)
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+
+		h := *l.host
+		h.Patterns = patterns
+		lines = append(lines, knownHostsLine{host: &h})
+	}
+	k.lines = lines
+	return removed
+}
+
+// Hash hashes host names of entries without markers, one host a line, like ssh-keygen -H.
+// Patterns with wildcards or negations could not be hashed and are kept as they are.
+func (k *KnownHosts) Hash() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var lines []knownHostsLine
+	for _, l := range k.lines {
+		if l.host == nil || l.host.Marker != "" {
+			lines = append(lines, l)
+			continue
+		}
+
+		var kept []string
+		for _, p := range l.host.Patterns {
+			if hasWildcard(p) || strings.HasPrefix(p, "|") {
+				kept = append(kept, p)
+				continue
+			}
+			h := *l.host
+			h.Patterns = []string{knownhosts.HashHostname(strings.ToLower(p))}
+			lines = append(lines, knownHostsLine{host: &h})
+		}
+		if len(kept) > 0 {
+			h := *l.host
+			h.Patterns = kept
+			lines = append(lines, knownHostsLine{host: &h})
+		}
+	}
+	k.lines = lines
+}
+
+// HostKeyMode tells a HostKeyCallback what to do with unknown hosts
+type HostKeyMode int
+
+const (
+	// Strict rejects unknown hosts
+	Strict HostKeyMode = iota
+	// TrustOnFirstUse adds keys of unknown hosts, and saves them if loaded by LoadKnownHostsFile.
+	// Hosts with changed keys are still rejected.
+	TrustOnFirstUse
+)
+
+// HostKeyCallback checks host keys and certificates against the known hosts, for ssh.ClientConfig.
+// Failures are errors of knownhosts, like *knownhosts.KeyError with no Want for unknown hosts,
+// or with the known keys for changed ones.
+// Like OpenSSH by default, keys are checked by the host name dialed but not its IP address.
+func (k *KnownHosts) HostKeyCallback(mode HostKeyMode) ssh.HostKeyCallback {
+	checker := &ssh.CertChecker{
+		SupportedCriticalOptions: supportedCriticalOptions,
+		IsHostAuthority:          k.isHostAuthority,
+		IsRevoked: func(cert *ssh.Certificate) bool {
+			return k.isRevoked(cert.SignatureKey)
+		},
+		HostKeyFallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return k.checkKey(mode, hostname, key)
+		},
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		check := key
+		if cert, ok := key.(*ssh.Certificate); ok {
+			check = cert.Key
+		}
+		if k.isRevoked(check) {
+			return &knownhosts.RevokedError{Revoked: knownhosts.KnownKey{Key: check}}
+		}
+		return checker.CheckHostKey(hostname, remote, key)
+	}
+}
+
+func (k *KnownHosts) isHostAuthority(auth ssh.PublicKey, host string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, l := range k.lines {
+		if l.host != nil && l.host.Marker == MarkerCertAuthority && l.host.Match(host) && keysEqual(l.host.Key, auth) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *KnownHosts) isRevoked(key ssh.PublicKey) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, l := range k.lines {
+		if l.host != nil && l.host.Marker == MarkerRevoked && keysEqual(l.host.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *KnownHosts) checkKey(mode HostKeyMode, hostname string, key ssh.PublicKey) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var want []knownhosts.KnownKey
+	for n, l := range k.lines {
+		if l.host == nil || l.host.Marker != "" || !l.host.Match(hostname) {
+			continue
+		}
+		if keysEqual(l.host.Key, key) {
+			return nil
+		}
+		want = append(want, knownhosts.KnownKey{Key: l.host.Key, Filename: k.path, Line: n + 1})
+	}
+
+	if len(want) > 0 || mode != TrustOnFirstUse {
+		return &knownhosts.KeyError{Want: want}
+	}
+
+	k.lines = append(k.lines, knownHostsLine{host: &KnownHost{Patterns: []string{normalizeHost(hostname)}, Key: key}})
+	if k.path == "" {
+		return nil
+	}
+	if e := k.save(); e != nil {
+		k.lines = k.lines[:len(k.lines)-1]
+		return e
+	}
+	return nil
+}
+
+func keysEqual(a, b ssh.PublicKey) bool {
+	return bytes.Equal(a.Marshal(), b.Marshal())
+}
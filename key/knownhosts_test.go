@@ -0,0 +1,139 @@
+package key
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestKnownHosts(t *testing.T) {
+	a, b, ca := newPublicKey(t), newPublicKey(t), newPublicKey(t)
+	data := "# training nodes\n" +
+		"node-1.example.com,10.0.0.1 " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(a))) + " node-1\n" +
+		"\n" +
+		"*.gpu.example.com,!bad.gpu.example.com " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(b))) + "\n" +
+		"@cert-authority *.example.com " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(ca))) + "\n"
+
+	k, e := ParseKnownHosts([]byte(data))
+	require.NoError(t, e)
+	assert.Equal(t, data, string(k.Marshal()))
+
+	entries := k.Entries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, []string{"node-1.example.com", "10.0.0.1"}, entries[0].Patterns)
+	assert.Equal(t, "node-1", entries[0].Comment)
+	assert.Equal(t, MarkerCertAuthority, entries[2].Marker)
+
+	assert.Len(t, k.Lookup("NODE-1.example.com"), 1)
+	assert.Len(t, k.Lookup("10.0.0.1:22"), 1)
+	assert.Empty(t, k.Lookup("10.0.0.1:2222"))
+	assert.Len(t, k.Lookup("a.gpu.example.com"), 1)
+	assert.Empty(t, k.Lookup("bad.gpu.example.com"))
+
+	k.Add([]string{"node-1.example.com", "node-2.example.com:2222"}, a)
+	assert.Len(t, k.Lookup("node-2.example.com:2222"), 1)
+	assert.Equal(t, "[node-2.example.com]:2222", k.Entries()[3].Patterns[0])
+	assert.Len(t, k.Lookup("node-1.example.com"), 1, "known already")
+
+	k.AddHashed([]string{"node-3.example.com"}, b)
+	assert.True(t, strings.HasPrefix(k.Entries()[4].Patterns[0], "|1|"))
+	assert.Len(t, k.Lookup("node-3.example.com"), 1)
+
+	assert.Equal(t, 1, k.Remove("node-1.example.com"))
+	assert.Empty(t, k.Lookup("node-1.example.com"))
+	assert.Len(t, k.Lookup("10.0.0.1"), 1)
+	assert.Equal(t, 1, k.Remove("node-3.example.com"))
+	assert.Equal(t, 0, k.Remove("a.gpu.example.com"))
+
+	k.Hash()
+	assert.Len(t, k.Lookup("10.0.0.1"), 1)
+	assert.Len(t, k.Lookup("node-2.example.com:2222"), 1)
+	assert.Len(t, k.Lookup("a.gpu.example.com"), 1)
+	assert.NotContains(t, string(k.Marshal()), "10.0.0.1")
+	assert.Contains(t, string(k.Marshal()), "*.gpu.example.com")
+
+	// interoperable with knownhosts of x/crypto
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, ioutil.WriteFile(path, k.Marshal(), 0644))
+	callback, e := knownhosts.New(path)
+	require.NoError(t, e)
+	assert.NoError(t, callback("10.0.0.1:22", tcpAddr("10.0.0.1"), a))
+	assert.NoError(t, callback("node-2.example.com:2222", tcpAddr("10.0.0.2"), a))
+
+	_, e = ParseKnownHosts([]byte("broken\n"))
+	assert.Error(t, e)
+}
+
+func tcpAddr(ip string) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 22}
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	a, b := newPublicKey(t), newPublicKey(t)
+	k, e := ParseKnownHosts(nil)
+	require.NoError(t, e)
+	k.Add([]string{"node-1.example.com"}, a)
+
+	strict := k.HostKeyCallback(Strict)
+	assert.NoError(t, strict("node-1.example.com:22", tcpAddr("10.0.0.1"), a))
+
+	var keyErr *knownhosts.KeyError
+	e = strict("node-1.example.com:22", tcpAddr("10.0.0.1"), b)
+	require.True(t, errors.As(e, &keyErr))
+	assert.Len(t, keyErr.Want, 1, "changed")
+	e = strict("node-2.example.com:22", tcpAddr("10.0.0.2"), b)
+	require.True(t, errors.As(e, &keyErr))
+	assert.Empty(t, keyErr.Want, "unknown")
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	k, e = LoadKnownHostsFile(path)
+	require.NoError(t, e)
+	tofu := k.HostKeyCallback(TrustOnFirstUse)
+	assert.NoError(t, tofu("node-2.example.com:2222", tcpAddr("10.0.0.2"), b))
+	assert.NoError(t, tofu("node-2.example.com:2222", tcpAddr("10.0.0.2"), b))
+	assert.Error(t, tofu("node-2.example.com:2222", tcpAddr("10.0.0.2"), a))
+
+	saved, e := LoadKnownHostsFile(path)
+	require.NoError(t, e)
+	assert.Len(t, saved.Entries(), 1)
+	assert.Len(t, saved.Lookup("node-2.example.com:2222"), 1)
+
+	saved.Revoke(b)
+	e = saved.HostKeyCallback(TrustOnFirstUse)("node-2.example.com:2222", tcpAddr("10.0.0.2"), b)
+	var revoked *knownhosts.RevokedError
+	assert.True(t, errors.As(e, &revoked))
+	require.NoError(t, saved.Save())
+}
+
+func TestHostKeyCallbackCertAuthority(t *testing.T) {
+	ca := newCA(t)
+	hostKey := newPublicKey(t)
+	cert, e := ca.SignHostCert(CertRequest{Key: hostKey, Principals: []string{"node-1.example.com"}, ValidBefore: time.Now().Add(time.Hour)})
+	require.NoError(t, e)
+
+	k, e := ParseKnownHosts(nil)
+	require.NoError(t, e)
+	k.AddCertAuthority([]string{"*.example.com"}, ca.PublicKey())
+	callback := k.HostKeyCallback(Strict)
+
+	assert.NoError(t, callback("node-1.example.com:22", tcpAddr("10.0.0.1"), cert))
+	assert.Error(t, callback("node-2.example.com:22", tcpAddr("10.0.0.2"), cert), "other principal")
+	assert.Error(t, callback("node-1.example.org:22", tcpAddr("10.0.0.1"), cert), "other domain")
+
+	other := newCA(t)
+	forged, e := other.SignHostCert(CertRequest{Key: hostKey, Principals: []string{"node-1.example.com"}, ValidBefore: time.Now().Add(time.Hour)})
+	require.NoError(t, e)
+	assert.Error(t, callback("node-1.example.com:22", tcpAddr("10.0.0.1"), forged))
+
+	k.Revoke(ca.PublicKey())
+	assert.Error(t, callback("node-1.example.com:22", tcpAddr("10.0.0.1"), cert))
+}
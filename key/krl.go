@@ -0,0 +1,420 @@
+package key
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	krlMagic         = "SSHKRL\n\x00"
+	krlFormatVersion = 1
+
+	krlSectionCertificates = 1
+	krlSectionExplicitKey  = 2
+	krlSectionSignature    = 4
+	krlSectionSHA256       = 5
+
+	krlCertSerialList   = 0x20
+	krlCertSerialRange  = 0x21
+	krlCertSerialBitmap = 0x22
+	krlCertKeyID        = 0x23
+
+	// krlMaxExpand limits serials expanded from ranges and bitmaps while parsing
+	krlMaxExpand = 1 << 20
+)
+
+// KRL is a key revocation list in the binary format of OpenSSH, see PROTOCOL.krl.
+// sshd takes it as RevokedKeys, and "ssh-keygen -Q -f" checks keys against it.
+type KRL struct {
+	Version   uint64
+	Generated time.Time
+	Comment   string
+
+	// CA signs the certificates revoked by Serials and KeyIDs
+	CA      ssh.PublicKey
+	Serials []uint64
+	KeyIDs  []string
+	// KeyHashes are SHA256 hashes of revoked public keys in the wire format
+	KeyHashes [][]byte
+}
+
+// RevokeSerial revokes certificates of the CA by serials
+func (k *KRL) RevokeSerial(serials ...uint64) {
+	k.Serials = append(k.Serials, serials...)
+	k.Version++
+}
+
+// RevokeKeyID revokes certificates of the CA by key IDs
+func (k *KRL) RevokeKeyID(ids ...string) {
+	k.KeyIDs = append(k.KeyIDs, ids...)
+	k.Version++
+}
+
+// RevokeKey revokes public keys, and all certificates of them.
+// A certificate is revoked by its certified key.
+func (k *KRL) RevokeKey(pubs ...ssh.PublicKey) {
+	for _, pub := range pubs {
+		if cert, ok := pub.(*ssh.Certificate); ok {
+			pub = cert.Key
+		}
+		h := sha256.Sum256(pub.Marshal())
+		k.KeyHashes = append(k.KeyHashes, h[:])
+	}
+	k.Version++
+}
+
+// IsRevoked tells if the key is revoked. A certificate is also revoked with its key or its CA.
+func (k *KRL) IsRevoked(pub ssh.PublicKey) bool {
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return k.keyRevoked(pub)
+	}
+
+	if k.keyRevoked(cert.Key) || k.keyRevoked(cert.SignatureKey) {
+		return true
+	}
+	if k.CA == nil || !bytes.Equal(cert.SignatureKey.Marshal(), k.CA.Marshal()) {
+		return false
+	}
+	for _, s := range k.Serials {
+		if s == cert.Serial {
+			return true
+		}
+	}
+	for _, id := range k.KeyIDs {
+		if id == cert.KeyId {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *KRL) keyRevoked(pub ssh.PublicKey) bool {
+	h := sha256.Sum256(pub.Marshal())
+	for _, revoked := range k.KeyHashes {
+		if bytes.Equal(revoked, h[:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *KRL) clone() *KRL {
+	out := *k
+	out.Serials = append([]uint64{}, k.Serials...)
+	out.KeyIDs = append([]string{}, k.KeyIDs...)
+	out.KeyHashes = append([][]byte{}, k.KeyHashes...)
+	return &out
+}
+
+// Marshal encodes the list in the binary format of OpenSSH, unsigned
+func (k *KRL) Marshal() []byte {
+	var b krlBuffer
+	b.WriteString(krlMagic)
+	b.uint32(krlFormatVersion)
+	b.uint64(k.Version)
+	if k.Generated.IsZero() {
+		b.uint64(0)
+	} else {
+		b.uint64(uint64(k.Generated.Unix()))
+	}
+	b.uint64(0) // flags
+	b.string(nil)
+	b.string([]byte(k.Comment))
+
+	if k.CA != nil && (len(k.Serials) > 0 || len(k.KeyIDs) > 0) {
+		var certs krlBuffer
+		certs.string(k.CA.Marshal())
+		certs.string(nil)
+
+		if serials := uniqueSerials(k.Serials); len(serials) > 0 {
+			var list krlBuffer
+			for _, s := range serials {
+				list.uint64(s)
+			}
+			certs.WriteByte(krlCertSerialList)
+			certs.string(list.Bytes())
+		}
+
+		if len(k.KeyIDs) > 0 {
+			ids := uniqueStrings(k.KeyIDs)
+			var list krlBuffer
+			for _, id := range ids {
+				list.string([]byte(id))
+			}
+			certs.WriteByte(krlCertKeyID)
+			certs.string(list.Bytes())
+		}
+
+		b.WriteByte(krlSectionCertificates)
+		b.string(certs.Bytes())
+	}
+
+	if len(k.KeyHashes) > 0 {
+		hashes := make([]string, len(k.KeyHashes))
+		for i, h := range k.KeyHashes {
+			hashes[i] = string(h)
+		}
+
+		var list krlBuffer
+		for _, h := range uniqueStrings(hashes) {
+			list.string([]byte(h))
+		}
+		b.WriteByte(krlSectionSHA256)
+		b.string(list.Bytes())
+	}
+
+	return b.Bytes()
+}
+
+// ParseKRL decodes a list in the binary format of OpenSSH, like the output of "ssh-keygen -k".
+// Signatures are ignored, and only certificates of one CA are supported.
+// Explicitly revoked keys are kept as their hashes.
+func ParseKRL(data []byte) (*KRL, error) {
+	r := krlReader{data: data}
+	if magic := r.next(len(krlMagic)); string(magic) != krlMagic {
+		return nil, errors.New("krl: bad magic")
+	}
+	if v := r.uint32(); v != krlFormatVersion {
+		return nil, fmt.Errorf("krl: unsupported format version %d", v)
+	}
+
+	k := &KRL{Version: r.uint64()}
+	if t := r.uint64(); t != 0 {
+		k.Generated = time.Unix(int64(t), 0)
+	}
+	r.uint64() // flags
+	r.string()
+	k.Comment = string(r.string())
+
+	for r.err == nil && len(r.data) > 0 {
+		typ := r.byte()
+		section := krlReader{data: r.string()}
+		if r.err != nil {
+			break
+		}
+
+		switch typ {
+		case krlSectionCertificates:
+			if e := k.parseCertificates(&section); e != nil {
+				return nil, e
+			}
+
+		case krlSectionExplicitKey:
+			for section.err == nil && len(section.data) > 0 {
+				h := sha256.Sum256(section.string())
+				k.KeyHashes = append(k.KeyHashes, h[:])
+			}
+
+		case krlSectionSHA256:
+			for section.err == nil && len(section.data) > 0 {
+				h := section.string()
+				if len(h) != sha256.Size {
+					return nil, errors.New("krl: bad sha256 hash")
+				}
+				k.KeyHashes = append(k.KeyHashes, h)
+			}
+
+		case krlSectionSignature:
+			// signatures are the last sections
+			return k, r.err
+
+		default:
+			return nil, fmt.Errorf("krl: unsupported section %d", typ)
+		}
+
+		if section.err != nil {
+			return nil, section.err
+		}
+	}
+
+	return k, r.err
+}
+
+func (k *KRL) parseCertificates(r *krlReader) error {
+	blob := r.string()
+	r.string()
+	if r.err != nil {
+		return r.err
+	}
+
+	if len(blob) > 0 {
+		ca, e := ssh.ParsePublicKey(blob)
+		if e != nil {
+			return fmt.Errorf("krl: parse ca: %w", e)
+		}
+		if k.CA != nil && !bytes.Equal(k.CA.Marshal(), blob) {
+			return errors.New("krl: certificates of multiple cas are not supported")
+		}
+		k.CA = ca
+	}
+
+	expand := func(min, max uint64) error {
+		if max < min || max-min >= krlMaxExpand || len(k.Serials)+int(max-min) >= krlMaxExpand {
+			return errors.New("krl: too many serials")
+		}
+		for s := min; ; s++ {
+			k.Serials = append(k.Serials, s)
+			if s == max {
+				return nil
+			}
+		}
+	}
+
+	for r.err == nil && len(r.data) > 0 {
+		typ := r.byte()
+		sub := krlReader{data: r.string()}
+		if r.err != nil {
+			break
+		}
+
+		switch typ {
+		case krlCertSerialList:
+			for sub.err == nil && len(sub.data) > 0 {
+				k.Serials = append(k.Serials, sub.uint64())
+			}
+
+		case krlCertSerialRange:
+			min, max := sub.uint64(), sub.uint64()
+			if sub.err == nil {
+				if e := expand(min, max); e != nil {
+					return e
+				}
+			}
+
+		case krlCertSerialBitmap:
+			offset := sub.uint64()
+			bitmap := new(big.Int).SetBytes(sub.string())
+			if bitmap.BitLen() > krlMaxExpand {
+				return errors.New("krl: too many serials")
+			}
+			for i := 0; i < bitmap.BitLen(); i++ {
+				if bitmap.Bit(i) == 1 {
+					k.Serials = append(k.Serials, offset+uint64(i))
+				}
+			}
+
+		case krlCertKeyID:
+			for sub.err == nil && len(sub.data) > 0 {
+				k.KeyIDs = append(k.KeyIDs, string(sub.string()))
+			}
+
+		default:
+			return fmt.Errorf("krl: unsupported certificate section %d", typ)
+		}
+
+		if sub.err != nil {
+			return sub.err
+		}
+	}
+	return r.err
+}
+
+func uniqueSerials(ss []uint64) []uint64 {
+	out := append([]uint64{}, ss...)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+
+	n := 0
+	for i, s := range out {
+		if i == 0 || s != out[n-1] {
+			out[n] = s
+			n++
+		}
+	}
+	return out[:n]
+}
+
+func uniqueStrings(ss []string) []string {
+	out := append([]string{}, ss...)
+	sort.Strings(out)
+
+	n := 0
+	for i, s := range out {
+		if i == 0 || s != out[n-1] {
+			out[n] = s
+			n++
+		}
+	}
+	return out[:n]
+}
+
+type krlBuffer struct {
+	bytes.Buffer
+}
+
+func (b *krlBuffer) uint32(v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (b *krlBuffer) uint64(v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (b *krlBuffer) string(s []byte) {
+	b.uint32(uint32(len(s)))
+	b.Write(s)
+}
+
+// krlReader keeps the first error, after which it reads zero values
+type krlReader struct {
+	data []byte
+	err  error
+}
+
+func (r *krlReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = errors.New("krl: truncated")
+		return nil
+	}
+	out := r.data[:n]
+	r.data = r.data[n:]
+	return out
+}
+
+func (r *krlReader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *krlReader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *krlReader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *krlReader) string() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if uint64(n) > uint64(len(r.data)) {
+		r.err = errors.New("krl: truncated")
+		return nil
+	}
+	return r.next(int(n))
+}
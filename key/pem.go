@@ -0,0 +1,161 @@
+package key
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type pemOptions struct {
+	passphrase []byte
+	pkcs8      bool
+}
+
+// PEMOption configures how private keys are encoded
+type PEMOption func(o *pemOptions)
+
+// Passphrase encrypts the private key, with the bcrypt KDF of OpenSSH by default, or PBES2 with PKCS8
+func Passphrase(p []byte) PEMOption {
+	return func(o *pemOptions) {
+		o.passphrase = p
+	}
+}
+
+// PKCS8 encodes the private key in PKCS#8 instead of the OpenSSH format,
+// as "ENCRYPTED PRIVATE KEY" with a Passphrase
+func PKCS8() PEMOption {
+	return func(o *pemOptions) {
+		o.pkcs8 = true
+	}
+}
+
+func marshalPrivateKey(priv crypto.Signer, comment string, opts []PEMOption) ([]byte, error) {
+	var o pemOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !o.pkcs8 {
+		var block *pem.Block
+		var e error
+		if len(o.passphrase) > 0 {
+			block, e = ssh.MarshalPrivateKeyWithPassphrase(priv, comment, o.passphrase)
+		} else {
+			block, e = ssh.MarshalPrivateKey(priv, comment)
+		}
+		if e != nil {
+			return nil, e
+		}
+		return pem.EncodeToMemory(block), nil
+	}
+
+	der, e := x509.MarshalPKCS8PrivateKey(priv)
+	if e != nil {
+		return nil, e
+	}
+	if len(o.passphrase) == 0 {
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	}
+
+	der, e = encryptPKCS8(der, o.passphrase)
+	if e != nil {
+		return nil, e
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}), nil
+}
+
+// LoadPrivateKey parses a private key in PEM, as OpenSSH, PKCS#1, SEC 1 or PKCS#8, encrypted or not.
+// The passphrase is required for encrypted keys and ignored otherwise.
+// It returns x509.IncorrectPasswordError for a wrong passphrase,
+// and *ssh.PassphraseMissingError if an encrypted key is given no passphrase.
+func LoadPrivateKey(pemBytes, passphrase []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	var raw interface{}
+	var e error
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		if len(passphrase) == 0 {
+			return nil, &ssh.PassphraseMissingError{}
+		}
+		var der []byte
+		if der, e = decryptPKCS8(block.Bytes, passphrase); e != nil {
+			return nil, e
+		}
+		if raw, e = x509.ParsePKCS8PrivateKey(der); e != nil {
+			// the padding happened to be valid
+			return nil, x509.IncorrectPasswordError
+		}
+
+	case block.Type == "PRIVATE KEY":
+		raw, e = x509.ParsePKCS8PrivateKey(block.Bytes)
+
+	case len(passphrase) > 0 && isEncrypted(block):
+		raw, e = ssh.ParseRawPrivateKeyWithPassphrase(pemBytes, passphrase)
+
+	default:
+		raw, e = ssh.ParseRawPrivateKey(pemBytes)
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	if k, ok := raw.(*ed25519.PrivateKey); ok {
+		raw = *k
+	}
+	signer, ok := raw.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key: %T", raw)
+	}
+	return signer, nil
+}
+
+// isEncrypted tells if a pem block of OpenSSH or the legacy PEM encryption is encrypted
+func isEncrypted(block *pem.Block) bool {
+	if block.Type != "OPENSSH PRIVATE KEY" {
+		return x509.IsEncryptedPEMBlock(block)
+	}
+
+	// an unencrypted key has "none" as the cipher name right after the magic
+	magic := []byte("openssh-key-v1\x00")
+	rest := bytes.TrimPrefix(block.Bytes, magic)
+	return len(rest) < 8 || !bytes.HasPrefix(rest, []byte("\x00\x00\x00\x04none"))
+}
+
+// AuthorizedKey is a line of an authorized_keys file
+type AuthorizedKey struct {
+	Key     ssh.PublicKey
+	Comment string
+	Options []string
+}
+
+// LoadAuthorizedKeys parses every key in an authorized_keys file, skipping blank lines and comments
+func LoadAuthorizedKeys(data []byte) ([]AuthorizedKey, error) {
+	var keys []AuthorizedKey
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, 1<<20)
+	for n := 1; s.Scan(); n++ {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		key, comment, options, _, e := ssh.ParseAuthorizedKey(line)
+		if e != nil {
+			return nil, fmt.Errorf("line %d: %w", n, e)
+		}
+		keys = append(keys, AuthorizedKey{Key: key, Comment: comment, Options: options})
+	}
+	return keys, s.Err()
+}
@@ -0,0 +1,175 @@
+package key
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// PBKDF2Iterations is the iteration count of PBKDF2-HMAC-SHA256 for encrypted PKCS#8 keys
+	PBKDF2Iterations = 600000
+	// MaxPBKDF2Iterations bounds the iteration count read from encrypted keys, so crafted keys cannot hog the CPU
+	MaxPBKDF2Iterations = 10000000
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// encryptedPrivateKeyInfo is defined in RFC 5208
+type encryptedPrivateKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Data      []byte
+}
+
+// pbes2Params is defined in RFC 8018
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// encryptPKCS8 encrypts a PKCS#8 private key with PBES2, using PBKDF2-HMAC-SHA256 and AES-256-CBC
+func encryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, e := rand.Read(salt); e != nil {
+		return nil, e
+	}
+	if _, e := rand.Read(iv); e != nil {
+		return nil, e
+	}
+
+	key := pbkdf2.Key(passphrase, salt, PBKDF2Iterations, 32, sha256.New)
+	block, e := aes.NewCipher(key)
+	if e != nil {
+		return nil, e
+	}
+
+	pad := aes.BlockSize - len(der)%aes.BlockSize
+	data := append(append([]byte{}, der...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	kdf, e := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: PBKDF2Iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if e != nil {
+		return nil, e
+	}
+	ivParam, e := asn1.Marshal(iv)
+	if e != nil {
+		return nil, e
+	}
+	params, e := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdf}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		Data:      data,
+	})
+}
+
+// decryptPKCS8 decrypts a PKCS#8 private key encrypted with PBES2,
+// which is what "openssl pkcs8 -topk8 -v2 aes256" and similar emit.
+// It returns x509.IncorrectPasswordError if the passphrase seems wrong.
+func decryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, e := asn1.Unmarshal(der, &info); e != nil {
+		return nil, fmt.Errorf("parse encrypted private key: %w", e)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption of private key: %s", info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, e := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); e != nil {
+		return nil, fmt.Errorf("parse pbes2 parameters: %w", e)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function: %s", params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, e := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); e != nil {
+		return nil, fmt.Errorf("parse pbkdf2 parameters: %w", e)
+	}
+	if kdf.Iterations < 1 || kdf.Iterations > MaxPBKDF2Iterations {
+		return nil, fmt.Errorf("pbkdf2 iteration count %d is out of [1, %d]", kdf.Iterations, MaxPBKDF2Iterations)
+	}
+	var prf func() hash.Hash
+	switch {
+	case kdf.PRF.Algorithm == nil, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported pbkdf2 prf: %s", kdf.PRF.Algorithm)
+	}
+
+	var keyLen int
+	switch alg := params.EncryptionScheme.Algorithm; {
+	case alg.Equal(oidAES128CBC):
+		keyLen = 16
+	case alg.Equal(oidAES192CBC):
+		keyLen = 24
+	case alg.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported cipher of private key: %s", alg)
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != keyLen {
+		return nil, fmt.Errorf("pbkdf2 key length %d does not match the cipher key size %d", kdf.KeyLength, keyLen)
+	}
+
+	var iv []byte
+	if _, e := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); e != nil {
+		return nil, fmt.Errorf("parse cipher iv: %w", e)
+	}
+	if len(iv) != aes.BlockSize || len(info.Data) == 0 || len(info.Data)%aes.BlockSize != 0 {
+		return nil, errors.New("malformed encrypted private key")
+	}
+
+	block, e := aes.NewCipher(pbkdf2.Key(passphrase, kdf.Salt, kdf.Iterations, keyLen, prf))
+	if e != nil {
+		return nil, e
+	}
+	data := make([]byte, len(info.Data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, info.Data)
+
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(data[len(data)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, x509.IncorrectPasswordError
+	}
+	return data[:len(data)-pad], nil
+}
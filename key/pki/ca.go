@@ -0,0 +1,287 @@
+// Package pki issues X.509 certificates from a private CA, for internal mTLS
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/supremind/pkg/internal/atomicfile"
+	"github.com/supremind/pkg/key"
+)
+
+const (
+	// DefaultCAValidity is how long a CA is valid if not set by ValidFor
+	DefaultCAValidity = 10 * 365 * 24 * time.Hour
+	// DefaultValidity is how long a leaf certificate is valid if not set by ValidFor
+	DefaultValidity = 90 * 24 * time.Hour
+
+	// clockSkew backdates certificates a little, in case clocks of peers drift
+	clockSkew = 5 * time.Minute
+)
+
+type options struct {
+	keyType      key.KeyType
+	validity     time.Duration
+	organization []string
+	dnsNames     []string
+	ips          []net.IP
+	now          time.Time
+}
+
+// Option configures a certificate to be created
+type Option func(o *options)
+
+// KeyType sets the type of the generated key, ECDSA P-256 by default. RSA keys are of key.DefaultRSABits.
+func KeyType(t key.KeyType) Option {
+	return func(o *options) {
+		o.keyType = t
+	}
+}
+
+// ValidFor sets how long the certificate is valid
+func ValidFor(d time.Duration) Option {
+	return func(o *options) {
+		o.validity = d
+	}
+}
+
+// Organization sets the organization in the subject
+func Organization(org ...string) Option {
+	return func(o *options) {
+		o.organization = append(o.organization, org...)
+	}
+}
+
+// DNSNames adds DNS names to subject alternative names
+func DNSNames(names ...string) Option {
+	return func(o *options) {
+		o.dnsNames = append(o.dnsNames, names...)
+	}
+}
+
+// IPAddresses adds IP addresses to subject alternative names
+func IPAddresses(ips ...net.IP) Option {
+	return func(o *options) {
+		o.ips = append(o.ips, ips...)
+	}
+}
+
+// IssuedAt sets when the certificate starts being valid, now by default
+func IssuedAt(t time.Time) Option {
+	return func(o *options) {
+		o.now = t
+	}
+}
+
+func newOptions(validity time.Duration, opts []Option) options {
+	o := options{keyType: key.ECDSAP256, validity: validity}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.now.IsZero() {
+		o.now = time.Now()
+	}
+	return o
+}
+
+func (o *options) template(commonName string) (*x509.Certificate, error) {
+	serial, e := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if e != nil {
+		return nil, e
+	}
+
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: o.organization},
+		NotBefore:    o.now.Add(-clockSkew),
+		NotAfter:     o.now.Add(o.validity),
+		DNSNames:     o.dnsNames,
+		IPAddresses:  o.ips,
+	}, nil
+}
+
+// CA is a certificate authority issuing server and client certificates
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// NewCA creates a self-signed CA
+func NewCA(commonName string, opts ...Option) (*CA, error) {
+	o := newOptions(DefaultCAValidity, opts)
+	tmpl, e := o.template(commonName)
+	if e != nil {
+		return nil, e
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.MaxPathLenZero = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	priv, e := key.GeneratePrivateKey(o.keyType, key.DefaultRSABits)
+	if e != nil {
+		return nil, e
+	}
+	cert, e := sign(tmpl, tmpl, priv.Public(), priv)
+	if e != nil {
+		return nil, e
+	}
+	return &CA{Cert: cert, Key: priv}, nil
+}
+
+// LoadCA loads a CA from PEM of the certificate and the unencrypted private key
+func LoadCA(certPEM, keyPEM []byte) (*CA, error) {
+	cert, e := parseCert(certPEM)
+	if e != nil {
+		return nil, e
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("certificate %s is not a ca", cert.Subject)
+	}
+
+	priv, e := key.LoadPrivateKey(keyPEM, nil)
+	if e != nil {
+		return nil, e
+	}
+	if !priv.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(cert.PublicKey) {
+		return nil, errors.New("private key does not match the ca certificate")
+	}
+	return &CA{Cert: cert, Key: priv}, nil
+}
+
+// IssueServer issues a certificate for servers, with names given by DNSNames and IPAddresses
+func (ca *CA) IssueServer(commonName string, opts ...Option) (*Certificate, error) {
+	return ca.issue(commonName, x509.ExtKeyUsageServerAuth, opts)
+}
+
+// IssueClient issues a certificate for clients
+func (ca *CA) IssueClient(commonName string, opts ...Option) (*Certificate, error) {
+	return ca.issue(commonName, x509.ExtKeyUsageClientAuth, opts)
+}
+
+func (ca *CA) issue(commonName string, usage x509.ExtKeyUsage, opts []Option) (*Certificate, error) {
+	o := newOptions(DefaultValidity, opts)
+	tmpl, e := o.template(commonName)
+	if e != nil {
+		return nil, e
+	}
+	if usage == x509.ExtKeyUsageServerAuth && len(tmpl.DNSNames) == 0 && len(tmpl.IPAddresses) == 0 {
+		return nil, errors.New("server certificate without dns names or ip addresses")
+	}
+	if tmpl.NotAfter.After(ca.Cert.NotAfter) {
+		tmpl.NotAfter = ca.Cert.NotAfter
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+
+	priv, e := key.GeneratePrivateKey(o.keyType, key.DefaultRSABits)
+	if e != nil {
+		return nil, e
+	}
+	if o.keyType == key.RSA {
+		// RSA keys also encipher keys in TLS 1.2 without forward secrecy
+		tmpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+
+	cert, e := sign(tmpl, ca.Cert, priv.Public(), ca.Key)
+	if e != nil {
+		return nil, e
+	}
+	return &Certificate{Cert: cert, Key: priv, CA: ca.Cert}, nil
+}
+
+func sign(tmpl, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) (*x509.Certificate, error) {
+	der, e := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, priv)
+	if e != nil {
+		return nil, e
+	}
+	return x509.ParseCertificate(der)
+}
+
+// CertPEM encodes the CA certificate
+func (ca *CA) CertPEM() []byte {
+	return certPEM(ca.Cert)
+}
+
+// KeyPEM encodes the private key of the CA in unencrypted PKCS#8
+func (ca *CA) KeyPEM() ([]byte, error) {
+	return keyPEM(ca.Key)
+}
+
+// Pool returns a pool trusting the CA
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return pool
+}
+
+// Certificate is a leaf certificate with its key, and the CA which issued it
+type Certificate struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+	CA   *x509.Certificate
+}
+
+// CertPEM encodes the certificate
+func (c *Certificate) CertPEM() []byte {
+	return certPEM(c.Cert)
+}
+
+// BundlePEM encodes the certificate followed by the CA certificate
+func (c *Certificate) BundlePEM() []byte {
+	return append(certPEM(c.Cert), certPEM(c.CA)...)
+}
+
+// KeyPEM encodes the private key in unencrypted PKCS#8, which tls.X509KeyPair loads
+func (c *Certificate) KeyPEM() ([]byte, error) {
+	return keyPEM(c.Key)
+}
+
+// WriteFiles replaces the certificate bundle and the private key atomically each,
+// the key is only readable by the owner even if the file existed with another mode.
+// The bundle is written first and the key last, so programs reloading the pair when the key file changes
+// see both new files. If the key fails to be written, the new bundle is left with the old key,
+// which fails to load as a pair instead of serving a stale one.
+func (c *Certificate) WriteFiles(certFile, keyFile string) error {
+	k, e := c.KeyPEM()
+	if e != nil {
+		return e
+	}
+	if e := atomicfile.Write(certFile, c.BundlePEM(), 0644, true); e != nil {
+		return e
+	}
+	return atomicfile.Write(keyFile, k, 0600, true)
+}
+
+// ExpiresWithin tells if the certificate is expired or expires within d after now
+func (c *Certificate) ExpiresWithin(now time.Time, d time.Duration) bool {
+	return !now.Add(d).Before(c.Cert.NotAfter)
+}
+
+func certPEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func keyPEM(priv crypto.Signer) ([]byte, error) {
+	der, e := x509.MarshalPKCS8PrivateKey(priv)
+	if e != nil {
+		return nil, e
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func parseCert(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,199 @@
+package pki
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supremind/pkg/clock"
+	"github.com/supremind/pkg/key"
+)
+
+func TestIssue(t *testing.T) {
+	ca, e := NewCA("training ca", Organization("supremind"))
+	require.NoError(t, e)
+	assert.True(t, ca.Cert.IsCA)
+	assert.Equal(t, []string{"supremind"}, ca.Cert.Subject.Organization)
+
+	server, e := ca.IssueServer("api", DNSNames("localhost"), IPAddresses(net.ParseIP("127.0.0.1")), ValidFor(time.Hour))
+	require.NoError(t, e)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, server.Cert.ExtKeyUsage)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), server.Cert.NotAfter, time.Minute)
+	_, e = server.Cert.Verify(x509.VerifyOptions{Roots: ca.Pool(), DNSName: "localhost"})
+	assert.NoError(t, e)
+
+	client, e := ca.IssueClient("worker-1", KeyType(key.Ed25519))
+	require.NoError(t, e)
+	_, e = client.Cert.Verify(x509.VerifyOptions{Roots: ca.Pool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	assert.NoError(t, e)
+	_, e = client.Cert.Verify(x509.VerifyOptions{Roots: ca.Pool()})
+	assert.Error(t, e, "not for servers")
+
+	_, e = ca.IssueServer("nameless")
+	assert.Error(t, e)
+
+	long, e := ca.IssueClient("forever", ValidFor(2*DefaultCAValidity))
+	require.NoError(t, e)
+	assert.Equal(t, ca.Cert.NotAfter, long.Cert.NotAfter)
+
+	assert.False(t, server.ExpiresWithin(time.Now(), 30*time.Minute))
+	assert.True(t, server.ExpiresWithin(time.Now(), 2*time.Hour))
+}
+
+func TestPEM(t *testing.T) {
+	ca, e := NewCA("training ca", KeyType(key.RSA))
+	require.NoError(t, e)
+	keyPEM, e := ca.KeyPEM()
+	require.NoError(t, e)
+	loaded, e := LoadCA(ca.CertPEM(), keyPEM)
+	require.NoError(t, e)
+	assert.True(t, loaded.Cert.Equal(ca.Cert))
+
+	other, e := NewCA("other ca")
+	require.NoError(t, e)
+	_, e = LoadCA(ca.CertPEM(), mustKeyPEM(t, other))
+	assert.Error(t, e)
+
+	server, e := loaded.IssueServer("api", DNSNames("api.internal"))
+	require.NoError(t, e)
+	assert.NotZero(t, server.Cert.KeyUsage&x509.KeyUsageDigitalSignature)
+
+	_, e = LoadCA(server.CertPEM(), mustKeyPEM(t, other))
+	assert.Error(t, e, "not a ca")
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "api.crt"), filepath.Join(dir, "api.key")
+	// a key file left readable by others is tightened when replaced
+	require.NoError(t, os.WriteFile(keyFile, nil, 0644))
+	require.NoError(t, server.WriteFiles(certFile, keyFile))
+	info, e := os.Stat(keyFile)
+	require.NoError(t, e)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	pair, e := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, e)
+	assert.Len(t, pair.Certificate, 2)
+}
+
+func mustKeyPEM(t *testing.T, ca *CA) []byte {
+	k, e := ca.KeyPEM()
+	require.NoError(t, e)
+	return k
+}
+
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) error {
+	l, e := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, e)
+	defer l.Close()
+
+	go func() {
+		conn, e := l.Accept()
+		if e != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("ok"))
+	}()
+
+	conn, e := tls.Dial("tcp", l.Addr().String(), clientConfig)
+	if e != nil {
+		return e
+	}
+	defer conn.Close()
+
+	// client certificates are verified after the handshake of TLS 1.3
+	_, e = io.ReadAll(conn)
+	return e
+}
+
+func TestTLSConfig(t *testing.T) {
+	ca, e := NewCA("training ca")
+	require.NoError(t, e)
+	server, e := ca.IssueServer("api", IPAddresses(net.ParseIP("127.0.0.1")))
+	require.NoError(t, e)
+	client, e := ca.IssueClient("worker-1")
+	require.NoError(t, e)
+
+	assert.NoError(t, handshake(t, ca.ServerTLSConfig(server), ca.ClientTLSConfig(client)))
+	assert.Error(t, handshake(t, ca.ServerTLSConfig(server), ca.ClientTLSConfig(nil)))
+
+	other, e := NewCA("other ca")
+	require.NoError(t, e)
+	stranger, e := other.IssueClient("stranger")
+	require.NoError(t, e)
+	assert.Error(t, handshake(t, ca.ServerTLSConfig(server), ca.ClientTLSConfig(stranger)))
+	assert.Error(t, handshake(t, ca.ServerTLSConfig(server), other.ClientTLSConfig(client)))
+}
+
+func TestRotator(t *testing.T) {
+	ca, e := NewCA("training ca")
+	require.NoError(t, e)
+
+	start := time.Now()
+	clk := clock.NewManual(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var failure error
+	issue := func() (*Certificate, error) {
+		if failure != nil {
+			return nil, failure
+		}
+		return ca.IssueServer("api", IPAddresses(net.ParseIP("127.0.0.1")), IssuedAt(clk.Now()), ValidFor(time.Hour))
+	}
+	_, e = NewRotator(issue, 2*time.Hour, RotateClock(clk))
+	assert.Error(t, e)
+
+	reported := make(chan error, 1)
+	r, e := NewRotator(issue, 10*time.Minute, RotateClock(clk), OnRotateError(func(e error) { reported <- e }))
+	require.NoError(t, e)
+	first := r.Current()
+
+	client, e := ca.IssueClient("worker-1")
+	require.NoError(t, e)
+	clientRotator, e := NewRotator(func() (*Certificate, error) { return client, nil }, 0)
+	require.NoError(t, e)
+	assert.NoError(t, handshake(t, r.ServerTLSConfig(ca.Pool()), clientRotator.ClientTLSConfig(ca.Pool())))
+
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	clk.BlockUntil(1)
+	clk.Advance(49 * time.Minute)
+	clk.BlockUntil(1)
+	assert.Same(t, first, r.Current())
+
+	clk.Advance(time.Minute)
+	clk.BlockUntil(1)
+	second := r.Current()
+	assert.False(t, first == second)
+	assert.Equal(t, start.Add(50*time.Minute+time.Hour).Unix(), second.Cert.NotAfter.Unix())
+
+	tc, e := r.GetCertificate(nil)
+	require.NoError(t, e)
+	assert.Equal(t, second.Cert.Raw, tc.Certificate[0])
+
+	// failures are reported and retried, keeping the current certificate
+	failure = io.ErrUnexpectedEOF
+	clk.Advance(50 * time.Minute)
+	assert.True(t, errors.Is(<-reported, io.ErrUnexpectedEOF))
+	clk.BlockUntil(1)
+	assert.Same(t, second, r.Current())
+
+	failure = nil
+	clk.Advance(RotateRetryInterval)
+	clk.BlockUntil(1)
+	assert.Equal(t, clk.Now().Add(time.Hour).Unix(), r.Current().Cert.NotAfter.Unix())
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
@@ -0,0 +1,127 @@
+package pki
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/supremind/pkg/clock"
+)
+
+// RotateRetryInterval is how long a Rotator waits after a failed reissue
+const RotateRetryInterval = time.Minute
+
+// Rotator keeps a certificate fresh by reissuing it before it expires
+type Rotator struct {
+	issue       func() (*Certificate, error)
+	renewBefore time.Duration
+	clock       clock.Clock
+	onError     func(error)
+
+	mu      sync.RWMutex
+	current *Certificate
+	tls     *tls.Certificate
+}
+
+// RotatorOption configures a Rotator
+type RotatorOption func(*Rotator)
+
+// RotateClock sets the clock telling when to reissue, clock.Real by default
+func RotateClock(c clock.Clock) RotatorOption {
+	return func(r *Rotator) {
+		r.clock = c
+	}
+}
+
+// OnRotateError sets a function receiving errors of reissues in Run, they are dropped by default
+func OnRotateError(f func(error)) RotatorOption {
+	return func(r *Rotator) {
+		r.onError = f
+	}
+}
+
+// NewRotator issues a certificate with issue, which is called again by Run
+// once the certificate expires within renewBefore
+func NewRotator(issue func() (*Certificate, error), renewBefore time.Duration, opts ...RotatorOption) (*Rotator, error) {
+	r := &Rotator{issue: issue, renewBefore: renewBefore, clock: clock.Real}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	c, e := issue()
+	if e != nil {
+		return nil, e
+	}
+	if c.ExpiresWithin(r.clock.Now(), renewBefore) {
+		return nil, fmt.Errorf("certificate expires at %s, within %s", c.Cert.NotAfter, renewBefore)
+	}
+	r.set(c)
+	return r, nil
+}
+
+func (r *Rotator) set(c *Certificate) {
+	tc := c.TLSCertificate()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = c
+	r.tls = &tc
+}
+
+// Current returns the current certificate
+func (r *Rotator) Current() *Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// GetCertificate could be used as GetCertificate of tls.Config
+func (r *Rotator) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tls, nil
+}
+
+// GetClientCertificate could be used as GetClientCertificate of tls.Config
+func (r *Rotator) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tls, nil
+}
+
+func (r *Rotator) untilRenewal(now time.Time) time.Duration {
+	return r.Current().Cert.NotAfter.Add(-r.renewBefore).Sub(now)
+}
+
+// Run reissues the certificate before it expires until ctx is done.
+// Failures are reported to the function of OnRotateError and retried after RotateRetryInterval,
+// the current certificate is kept meanwhile.
+func (r *Rotator) Run(ctx context.Context) error {
+	for {
+		wait := r.untilRenewal(r.clock.Now())
+		if wait <= 0 {
+			if c, e := r.issue(); e != nil {
+				if r.onError != nil {
+					r.onError(fmt.Errorf("reissue certificate: %w", e))
+				}
+			} else {
+				r.set(c)
+				wait = r.untilRenewal(r.clock.Now())
+			}
+
+			if wait <= 0 {
+				wait = RotateRetryInterval
+			}
+		}
+
+		tm := r.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			tm.Stop()
+			return ctx.Err()
+		case <-tm.C():
+		}
+	}
+}
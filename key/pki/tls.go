@@ -0,0 +1,57 @@
+package pki
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// TLSCertificate converts the certificate for tls.Config, with the CA certificate in its chain
+func (c *Certificate) TLSCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{c.Cert.Raw, c.CA.Raw},
+		PrivateKey:  c.Key,
+		Leaf:        c.Cert,
+	}
+}
+
+// ServerTLSConfig serves the certificate, and requires client certificates issued by the CA
+func (ca *CA) ServerTLSConfig(cert *Certificate) *tls.Config {
+	tc := cert.TLSCertificate()
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{tc},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.Pool(),
+	}
+}
+
+// ClientTLSConfig trusts servers with certificates issued by the CA, and presents the client certificate if not nil
+func (ca *CA) ClientTLSConfig(cert *Certificate) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    ca.Pool(),
+	}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{cert.TLSCertificate()}
+	}
+	return cfg
+}
+
+// ServerTLSConfig serves the current certificate of the rotator, and requires client certificates issued by pool
+func (r *Rotator) ServerTLSConfig(clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+		ClientCAs:      clientCAs,
+	}
+}
+
+// ClientTLSConfig presents the current certificate of the rotator, and trusts servers with certificates issued by pool
+func (r *Rotator) ClientTLSConfig(rootCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		GetClientCertificate: r.GetClientCertificate,
+		RootCAs:              rootCAs,
+	}
+}
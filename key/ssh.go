@@ -1,10 +1,16 @@
 package key
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
+	"io"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -26,3 +32,138 @@ func NewSSHKeyPairs() (priv, pub []byte, e error) {
 
 	return
 }
+
+// KeyType is the algorithm of a generated key
+type KeyType string
+
+const (
+	Ed25519   KeyType = "ed25519"
+	ECDSAP256 KeyType = "ecdsa-p256"
+	ECDSAP384 KeyType = "ecdsa-p384"
+	ECDSAP521 KeyType = "ecdsa-p521"
+	RSA       KeyType = "rsa"
+)
+
+const (
+	// DefaultRSABits is the size of RSA keys if not given by Bits
+	DefaultRSABits = 3072
+	// MinRSABits is the smallest RSA key size accepted
+	MinRSABits = 3072
+)
+
+type sshKeyOptions struct {
+	typ     KeyType
+	bits    int
+	comment string
+	rand    io.Reader
+}
+
+// SSHKeyOption configures GenerateSSHKey
+type SSHKeyOption func(o *sshKeyOptions)
+
+// OfType sets the key algorithm, Ed25519 by default
+func OfType(t KeyType) SSHKeyOption {
+	return func(o *sshKeyOptions) {
+		o.typ = t
+	}
+}
+
+// Bits sets the size of RSA keys, DefaultRSABits by default
+func Bits(n int) SSHKeyOption {
+	return func(o *sshKeyOptions) {
+		o.bits = n
+	}
+}
+
+// Comment sets the comment in the private key and the authorized_keys line, usually like "user@host"
+func Comment(c string) SSHKeyOption {
+	return func(o *sshKeyOptions) {
+		o.comment = c
+	}
+}
+
+// Rand sets the source of randomness, crypto/rand by default
+func Rand(r io.Reader) SSHKeyOption {
+	return func(o *sshKeyOptions) {
+		o.rand = r
+	}
+}
+
+// SSHKey is a generated key pair
+type SSHKey struct {
+	Private crypto.Signer
+	Public  ssh.PublicKey
+	Comment string
+}
+
+// GenerateSSHKey generates an Ed25519 key, or one of the type given by OfType
+func GenerateSSHKey(opts ...SSHKeyOption) (*SSHKey, error) {
+	o := sshKeyOptions{typ: Ed25519, bits: DefaultRSABits, rand: rand.Reader}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	priv, e := generatePrivateKey(o.typ, o.bits, o.rand)
+	if e != nil {
+		return nil, e
+	}
+
+	pub, e := ssh.NewPublicKey(priv.Public())
+	if e != nil {
+		return nil, e
+	}
+
+	return &SSHKey{Private: priv, Public: pub, Comment: o.comment}, nil
+}
+
+// GeneratePrivateKey generates a key of the type, bits is the size of RSA keys and ignored for other types
+func GeneratePrivateKey(t KeyType, bits int) (crypto.Signer, error) {
+	return generatePrivateKey(t, bits, rand.Reader)
+}
+
+func generatePrivateKey(t KeyType, bits int, r io.Reader) (priv crypto.Signer, e error) {
+	switch t {
+	case Ed25519:
+		_, priv, e = ed25519.GenerateKey(r)
+	case ECDSAP256:
+		priv, e = ecdsa.GenerateKey(elliptic.P256(), r)
+	case ECDSAP384:
+		priv, e = ecdsa.GenerateKey(elliptic.P384(), r)
+	case ECDSAP521:
+		priv, e = ecdsa.GenerateKey(elliptic.P521(), r)
+	case RSA:
+		if bits < MinRSABits {
+			return nil, fmt.Errorf("rsa key size %d is less than %d", bits, MinRSABits)
+		}
+		priv, e = rsa.GenerateKey(r, bits)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", t)
+	}
+	return
+}
+
+// PrivateKeyPEM encodes the private key in the OpenSSH format, or as configured by options
+func (k *SSHKey) PrivateKeyPEM(opts ...PEMOption) ([]byte, error) {
+	return marshalPrivateKey(k.Private, k.Comment, opts)
+}
+
+// AuthorizedKey returns the public key as a line of authorized_keys, with the comment if any
+func (k *SSHKey) AuthorizedKey() []byte {
+	return authorizedKey(k.Public, k.Comment)
+}
+
+// Fingerprint returns the SHA256 fingerprint of the public key, like "SHA256:..." as ssh-keygen -l shows
+func (k *SSHKey) Fingerprint() string {
+	return ssh.FingerprintSHA256(k.Public)
+}
+
+func authorizedKey(pub ssh.PublicKey, comment string) []byte {
+	line := ssh.MarshalAuthorizedKey(pub)
+	if comment == "" {
+		return line
+	}
+
+	line = append(line[:len(line)-1], ' ')
+	line = append(line, comment...)
+	return append(line, '\n')
+}
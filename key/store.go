@@ -0,0 +1,269 @@
+package key
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/supremind/pkg/internal/atomicfile"
+)
+
+var (
+	// ErrKeyNotFound is returned by a Store for unknown names
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyExists is returned by Store.Save for a name in use, unless Overwrite is given
+	ErrKeyExists = errors.New("key already exists")
+	// ErrInsecureKey is returned by FileStore.Load for private keys readable by others
+	ErrInsecureKey = errors.New("private key is accessible by others")
+)
+
+type saveOptions struct {
+	overwrite bool
+}
+
+// SaveOption configures Store.Save
+type SaveOption func(o *saveOptions)
+
+// Overwrite replaces the key if there is one of the name
+func Overwrite() SaveOption {
+	return func(o *saveOptions) {
+		o.overwrite = true
+	}
+}
+
+// Store saves key pairs by names, like the output of NewSSHKeyPairs or SSHKey.PrivateKeyPEM and SSHKey.AuthorizedKey
+type Store interface {
+	// Save stores the key pair, pub could be nil
+	Save(name string, priv, pub []byte, opts ...SaveOption) error
+	// Load returns the key pair, pub is nil if not saved
+	Load(name string) (priv, pub []byte, e error)
+	Delete(name string) error
+	// List returns names in order
+	List() ([]string, error)
+}
+
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") ||
+		strings.ContainsAny(name, `/\`) || strings.HasSuffix(name, pubSuffix) {
+		return fmt.Errorf("invalid key name: %q", name)
+	}
+	return nil
+}
+
+const pubSuffix = ".pub"
+
+// FileStore saves private keys in files of mode 0600 and public keys in ".pub" files next to them, like ssh-keygen.
+// Files are written to temporary files, synced and then renamed, so they are never partially written.
+// The private key is replaced first, and the public key is removed if it fails to be replaced,
+// so a public key is never left with another private key.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory with mode 0700 if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if e := os.MkdirAll(dir, 0700); e != nil {
+		return nil, fmt.Errorf("create key store directory: %w", e)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Path returns the path of the private key, the public key is in the same path with ".pub"
+func (s *FileStore) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *FileStore) Save(name string, priv, pub []byte, opts ...SaveOption) error {
+	if e := checkName(name); e != nil {
+		return e
+	}
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.Path(name)
+	if e := atomicfile.Write(path, priv, 0600, o.overwrite); e != nil {
+		if errors.Is(e, os.ErrExist) {
+			return fmt.Errorf("%w: %s", ErrKeyExists, name)
+		}
+		return e
+	}
+
+	if pub == nil {
+		if e := os.Remove(path + pubSuffix); e != nil && !os.IsNotExist(e) {
+			return e
+		}
+		return nil
+	}
+	if e := atomicfile.Write(path+pubSuffix, pub, 0644, true); e != nil {
+		if re := os.Remove(path + pubSuffix); re != nil && !os.IsNotExist(re) {
+			return fmt.Errorf("%w, and remove the stale public key: %v", e, re)
+		}
+		return e
+	}
+	return nil
+}
+
+// Load reads the key pair, and refuses private keys accessible by group or others with ErrInsecureKey, like ssh does
+func (s *FileStore) Load(name string) (priv, pub []byte, e error) {
+	if e := checkName(name); e != nil {
+		return nil, nil, e
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.Path(name)
+	f, e := os.Open(path)
+	if os.IsNotExist(e) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	} else if e != nil {
+		return nil, nil, e
+	}
+	defer f.Close()
+
+	// checks the opened file, which could not be replaced meanwhile
+	info, e := f.Stat()
+	if e != nil {
+		return nil, nil, e
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return nil, nil, fmt.Errorf("%w: %s has mode %04o", ErrInsecureKey, path, perm)
+	}
+
+	if priv, e = ioutil.ReadAll(f); e != nil {
+		return nil, nil, fmt.Errorf("read %s: %w", path, e)
+	}
+
+	pub, e = ioutil.ReadFile(path + pubSuffix)
+	if e != nil && !os.IsNotExist(e) {
+		return nil, nil, e
+	}
+	return priv, pub, nil
+}
+
+func (s *FileStore) Delete(name string) error {
+	if e := checkName(name); e != nil {
+		return e
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.Path(name)
+	if e := os.Remove(path); os.IsNotExist(e) {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	} else if e != nil {
+		return e
+	}
+	if e := os.Remove(path + pubSuffix); e != nil && !os.IsNotExist(e) {
+		return e
+	}
+	return nil
+}
+
+func (s *FileStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, e := ioutil.ReadDir(s.dir)
+	if e != nil {
+		return nil, e
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && checkName(entry.Name()) == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// MemoryStore keeps keys in memory, for tests
+type MemoryStore struct {
+	mu   sync.Mutex
+	keys map[string][2][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string][2][]byte)}
+}
+
+func (s *MemoryStore) Save(name string, priv, pub []byte, opts ...SaveOption) error {
+	if e := checkName(name); e != nil {
+		return e
+	}
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[name]; ok && !o.overwrite {
+		return fmt.Errorf("%w: %s", ErrKeyExists, name)
+	}
+	s.keys[name] = [2][]byte{copyBytes(priv), copyBytes(pub)}
+	return nil
+}
+
+func (s *MemoryStore) Load(name string) (priv, pub []byte, e error) {
+	if e := checkName(name); e != nil {
+		return nil, nil, e
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pair, ok := s.keys[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	return copyBytes(pair[0]), copyBytes(pair[1]), nil
+}
+
+func (s *MemoryStore) Delete(name string) error {
+	if e := checkName(name); e != nil {
+		return e
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	delete(s.keys, name)
+	return nil
+}
+
+func (s *MemoryStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.keys))
+	for name := range s.keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
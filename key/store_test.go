@@ -0,0 +1,105 @@
+package key
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStore(t *testing.T, s Store) {
+	rsaKey, e := GenerateSSHKey(OfType(RSA))
+	require.NoError(t, e)
+	priv, e := rsaKey.PrivateKeyPEM()
+	require.NoError(t, e)
+	pub := rsaKey.AuthorizedKey()
+
+	require.NoError(t, s.Save("id_rsa", priv, pub))
+	e = s.Save("id_rsa", []byte("other"), nil)
+	assert.True(t, errors.Is(e, ErrKeyExists), "%v", e)
+
+	gotPriv, gotPub, e := s.Load("id_rsa")
+	require.NoError(t, e)
+	assert.Equal(t, priv, gotPriv)
+	assert.Equal(t, pub, gotPub)
+
+	k, e := GenerateSSHKey()
+	require.NoError(t, e)
+	edPriv, e := k.PrivateKeyPEM()
+	require.NoError(t, e)
+	require.NoError(t, s.Save("id_rsa", edPriv, k.AuthorizedKey(), Overwrite()))
+	gotPriv, gotPub, e = s.Load("id_rsa")
+	require.NoError(t, e)
+	assert.Equal(t, edPriv, gotPriv)
+	assert.Equal(t, k.AuthorizedKey(), gotPub)
+
+	require.NoError(t, s.Save("deploy", edPriv, nil))
+	_, gotPub, e = s.Load("deploy")
+	require.NoError(t, e)
+	assert.Nil(t, gotPub)
+
+	names, e := s.List()
+	require.NoError(t, e)
+	assert.Equal(t, []string{"deploy", "id_rsa"}, names)
+
+	require.NoError(t, s.Delete("deploy"))
+	_, _, e = s.Load("deploy")
+	assert.True(t, errors.Is(e, ErrKeyNotFound), "%v", e)
+	assert.True(t, errors.Is(s.Delete("deploy"), ErrKeyNotFound))
+
+	for _, name := range []string{"", "../id_rsa", ".hidden", "id_rsa.pub"} {
+		assert.Error(t, s.Save(name, priv, pub), name)
+		_, _, e := s.Load(name)
+		assert.False(t, e == nil || errors.Is(e, ErrKeyNotFound), "%s: %v", name, e)
+		e = s.Delete(name)
+		assert.False(t, e == nil || errors.Is(e, ErrKeyNotFound), "%s: %v", name, e)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	s, e := NewFileStore(filepath.Join(dir, "keys"))
+	require.NoError(t, e)
+	testStore(t, s)
+
+	info, e := os.Stat(filepath.Join(dir, "keys"))
+	require.NoError(t, e)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	info, e = os.Stat(s.Path("id_rsa"))
+	require.NoError(t, e)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, e = os.Stat(s.Path("id_rsa") + ".pub")
+	require.NoError(t, e)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// no temporary files left
+	entries, e := ioutil.ReadDir(filepath.Join(dir, "keys"))
+	require.NoError(t, e)
+	assert.Len(t, entries, 2)
+
+	// a public key failing to be replaced is removed instead of left with the new private key
+	require.NoError(t, os.Remove(s.Path("id_rsa")+".pub"))
+	require.NoError(t, os.Mkdir(s.Path("id_rsa")+".pub", 0700))
+	k, e := GenerateSSHKey()
+	require.NoError(t, e)
+	priv, e := k.PrivateKeyPEM()
+	require.NoError(t, e)
+	assert.Error(t, s.Save("id_rsa", priv, k.AuthorizedKey(), Overwrite()))
+	gotPriv, gotPub, e := s.Load("id_rsa")
+	require.NoError(t, e)
+	assert.Equal(t, priv, gotPriv)
+	assert.Nil(t, gotPub)
+
+	require.NoError(t, os.Chmod(s.Path("id_rsa"), 0644))
+	_, _, e = s.Load("id_rsa")
+	assert.True(t, errors.Is(e, ErrInsecureKey), "%v", e)
+}
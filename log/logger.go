@@ -3,6 +3,7 @@ package log
 import (
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
+	"github.com/supremind/pkg/errs"
 	"go.uber.org/zap"
 )
 
@@ -15,8 +16,8 @@ const (
 var ZLog = ZapLogger(true)
 
 // Log is a global base logger.
-// It uses a dev ZapLogger by default.
-var Log = zapr.NewLogger(ZLog)
+// It uses a dev ZapLogger by default, and logs errors with their chains and stacks, see NewLogger.
+var Log = NewLogger(ZLog)
 
 // ZapLogger is a Logger implementation.
 // If development is true, a Zap development config will be used
@@ -53,3 +54,45 @@ func WithName(n string) logr.Logger {
 func WithValues(kv ...interface{}) logr.Logger {
 	return Log.WithValues(kv...)
 }
+
+// NewLogger creates a logr.Logger backed by a zap logger, like zapr does,
+// but its Error logs the error as an object with "message", "chain" and "stack" fields, see errs.Loggable.
+// Like loggers from ZapLogger, zl should skip a caller frame for zapr.
+func NewLogger(zl *zap.Logger) logr.Logger {
+	return &errorLogger{
+		Logger: zapr.NewLogger(zl),
+		// skips errorLogger.Error as well
+		errors: zapr.NewLogger(zl.WithOptions(zap.AddCallerSkip(1))),
+	}
+}
+
+type errorLogger struct {
+	logr.Logger
+	errors logr.Logger
+}
+
+func (l *errorLogger) Error(err error, msg string, kv ...interface{}) {
+	if err == nil {
+		l.errors.Error(nil, msg, kv...)
+		return
+	}
+
+	fields := make([]interface{}, 0, len(kv)+2)
+	fields = append(fields, kv...)
+	fields = append(fields, "error", errs.Loggable(err))
+	l.errors.Error(nil, msg, fields...)
+}
+
+func (l *errorLogger) WithValues(kv ...interface{}) logr.Logger {
+	return &errorLogger{
+		Logger: l.Logger.WithValues(kv...),
+		errors: l.errors.WithValues(kv...),
+	}
+}
+
+func (l *errorLogger) WithName(n string) logr.Logger {
+	return &errorLogger{
+		Logger: l.Logger.WithName(n),
+		errors: l.errors.WithName(n),
+	}
+}
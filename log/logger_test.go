@@ -0,0 +1,41 @@
+package log
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supremind/pkg/errs"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestErrorFields(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := NewLogger(zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))).WithName("test").WithValues("job", 1)
+
+	l.Error(errs.Wrap(io.EOF, "read body"), "request failed", "path", "/")
+	l.Error(nil, "nothing went wrong")
+	l.Info("done")
+
+	require.Equal(t, 3, logs.Len())
+	entries := logs.All()
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "/", fields["path"])
+	assert.Equal(t, int64(1), fields["job"])
+	e := fields["error"].(map[string]interface{})
+	assert.Equal(t, "read body: EOF", e["message"])
+	assert.Equal(t, []interface{}{"read body", "EOF"}, e["chain"])
+	assert.Contains(t, e["stack"], "log.TestErrorFields")
+	assert.Equal(t, "test", entries[0].LoggerName)
+
+	assert.NotContains(t, entries[1].ContextMap(), "error")
+
+	for _, entry := range entries {
+		assert.Equal(t, "logger_test.go", filepath.Base(entry.Caller.File))
+	}
+}
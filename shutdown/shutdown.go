@@ -6,11 +6,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+
+	"github.com/supremind/pkg/errs"
 )
 
-// BornToDie blocks until being interrupted or cancelled
+// BornToDie blocks until being interrupted or cancelled, then runs the handlers in order.
+// A panicking handler is logged and does not stop the others.
 func BornToDie(ctx context.Context, handlers ...func()) {
-	signals := make(chan os.Signal)
+	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGKILL, syscall.SIGHUP)
 
 	select {
@@ -22,6 +25,14 @@ func BornToDie(ctx context.Context, handlers ...func()) {
 
 	signal.Stop(signals)
 	for _, h := range handlers {
-		h()
+		if e := run(h); e != nil {
+			log.Printf("shutdown handler failed: %+v\n", e)
+		}
 	}
 }
+
+func run(h func()) (err error) {
+	defer errs.Recover(&err)
+	h()
+	return nil
+}
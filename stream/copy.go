@@ -6,6 +6,7 @@ import (
 	"io"
 	"sync"
 
+	"github.com/supremind/pkg/errs"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -29,8 +30,9 @@ func DoubleBufferedCopy(w io.Writer, r io.Reader, bufSize int) (n int64, e error
 		return nil
 	})
 
-	eg.Go(func() error {
+	eg.Go(func() (err error) {
 		defer close(full)
+		defer errs.Recover(&err)
 
 		for {
 			select {
@@ -42,7 +44,12 @@ func DoubleBufferedCopy(w io.Writer, r io.Reader, bufSize int) (n int64, e error
 				n, e := r.Read(buf)
 				rn += int64(n)
 				buf = buf[:n]
-				full <- buf
+				select {
+				case full <- buf:
+				case <-ctx.Done():
+					// the writer has failed
+					return ctx.Err()
+				}
 
 				if e != nil {
 					if e == io.EOF {
@@ -54,7 +61,9 @@ func DoubleBufferedCopy(w io.Writer, r io.Reader, bufSize int) (n int64, e error
 		}
 	})
 
-	eg.Go(func() error {
+	eg.Go(func() (err error) {
+		defer errs.Recover(&err)
+
 		// write as many as it could, ignore ctx.Done
 		for buf := range full {
 			if len(buf) == 0 {
@@ -76,10 +85,8 @@ func DoubleBufferedCopy(w io.Writer, r io.Reader, bufSize int) (n int64, e error
 		return nil
 	})
 
+	// buffers are not drained from empty, a failed goroutine may not give them back
 	e = eg.Wait()
-	<-empty
-	<-empty
-	close(empty)
 
 	n = wn
 	if e == nil && wn < rn {
@@ -129,7 +136,13 @@ func CopyInBlocks(ctx context.Context, wa io.WriterAt, ra io.ReaderAt, size, blo
 				to = size
 			}
 
-			buf := <-bufs
+			var buf []byte
+			select {
+			case buf = <-bufs:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
 			select {
 			case rJobs <- copyJob{from: from, to: to, buf: buf}:
 				from = to
@@ -144,8 +157,9 @@ func CopyInBlocks(ctx context.Context, wa io.WriterAt, ra io.ReaderAt, size, blo
 	wg.Add(workers)
 
 	for i := 0; i < workers; i++ {
-		eg.Go(func() error {
+		eg.Go(func() (err error) {
 			defer wg.Done()
+			defer errs.Recover(&err)
 
 			for {
 				select {
@@ -171,7 +185,11 @@ func CopyInBlocks(ctx context.Context, wa io.WriterAt, ra io.ReaderAt, size, blo
 					}
 
 					job.buf = buf[:n]
-					wJobs <- job
+					select {
+					case wJobs <- job:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
 				}
 			}
 		})
@@ -184,7 +202,9 @@ func CopyInBlocks(ctx context.Context, wa io.WriterAt, ra io.ReaderAt, size, blo
 	})
 
 	for i := 0; i < workers; i++ {
-		eg.Go(func() error {
+		eg.Go(func() (err error) {
+			defer errs.Recover(&err)
+
 			for {
 				select {
 				case <-ctx.Done():
@@ -218,9 +238,5 @@ func CopyInBlocks(ctx context.Context, wa io.WriterAt, ra io.ReaderAt, size, blo
 	if e == nil {
 		n = size
 	}
-
-	for i := 0; i < workers; i++ {
-		<-bufs
-	}
 	return
 }
@@ -2,11 +2,14 @@ package stream
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/supremind/pkg/errs"
 )
 
 type slowWriter struct {
@@ -41,6 +44,53 @@ func TestDoubleBufferedCopy(t *testing.T) {
 	assert.Equal(t, content, output.Bytes())
 }
 
+type panicReader struct {
+	r     io.Reader
+	after int
+}
+
+func (pr *panicReader) Read(p []byte) (n int, e error) {
+	if pr.after <= 0 {
+		panic("broken reader")
+	}
+	pr.after--
+	return pr.r.Read(p)
+}
+
+type panicWriter struct{}
+
+func (panicWriter) Write(p []byte) (n int, e error) {
+	panic("broken writer")
+}
+
+type panicWriterAt struct{}
+
+func (panicWriterAt) WriteAt(p []byte, off int64) (n int, e error) {
+	panic("broken writer")
+}
+
+func TestDoubleBufferedCopyPanic(t *testing.T) {
+	input := &panicReader{r: bytes.NewBuffer(bytes.Repeat([]byte("0123456789abcdef"), 16)), after: 3}
+	_, e := DoubleBufferedCopy(&bytes.Buffer{}, input, 16)
+
+	var p *errs.PanicError
+	assert.True(t, errors.As(e, &p))
+	assert.Equal(t, "broken reader", p.Value)
+
+	_, e = DoubleBufferedCopy(panicWriter{}, bytes.NewBuffer(make([]byte, 1024)), 16)
+	assert.True(t, errors.As(e, &p))
+	assert.Equal(t, "broken writer", p.Value)
+}
+
+func TestCopyInBlocksPanic(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 16)
+	_, e := CopyInBlocks(context.Background(), panicWriterAt{}, bytes.NewReader(content), int64(len(content)), 15, 4)
+
+	var p *errs.PanicError
+	assert.True(t, errors.As(e, &p))
+	assert.Equal(t, "broken writer", p.Value)
+}
+
 /*
 BenchmarkDoubleBufferedCopy-4                 13          91187478 ns/op            4841 B/op         27 allocs/op
 BenchmarkStdCopy-4                             6         178568924 ns/op            3578 B/op          8 allocs/op